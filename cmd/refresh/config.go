@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	refresh "github.com/atterpac/refresh/engine"
+	"github.com/atterpac/refresh/process"
+)
+
+// runConfig implements `refresh config print [flags]`: it resolves the config
+// exactly as the watcher would — the discovered or -f file, its profile, the
+// REFRESH_* environment and explicitly set flags — and prints every setting
+// with where its value came from.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return errors.New("usage: refresh config print [flags]")
+	}
+	f, err := parseFlags(args[1:])
+	if err != nil {
+		return err
+	}
+	if err := f.discover(); err != nil {
+		return err
+	}
+	eng, err := newEngine(f)
+	if err != nil {
+		return err
+	}
+	return printConfig(os.Stdout, f, &eng.Config)
+}
+
+// printConfig writes the effective config, one setting per line, each
+// followed by its source: default, file, profile, env or flag.
+func printConfig(w io.Writer, f cliFlags, cfg *refresh.Config) error {
+	if f.configPath != "" {
+		fmt.Fprintf(w, "# config file: %s\n", f.configPath)
+	}
+	if cfg.Profile != "" {
+		fmt.Fprintf(w, "# profile: %s\n", cfg.Profile)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, s := range refresh.Settings {
+		source := cfg.Source(s.Key)
+		switch v := s.Value(cfg).(type) {
+		case []process.Execute:
+			// An exec list is turned into executes when the config is loaded.
+			if source == "default" && len(cfg.ExecList) > 0 {
+				source = cfg.Source("exec_list")
+			}
+			if len(v) == 0 {
+				fmt.Fprintf(tw, "%s\t[]\t# %s\n", s.Key, source)
+			}
+			for i, exe := range v {
+				fmt.Fprintf(tw, "%s[%d]\t%s\t# %s\n", s.Key, i, formatExecute(exe), source)
+			}
+		case process.Execute:
+			fmt.Fprintf(tw, "%s\t%s\t# %s\n", s.Key, formatExecute(v), source)
+		default:
+			fmt.Fprintf(tw, "%s\t%s\t# %s\n", s.Key, formatValue(v), source)
+		}
+	}
+	return tw.Flush()
+}
+
+// formatValue renders a setting value in TOML syntax.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatExecute renders an execute as a TOML inline table of its set fields.
+func formatExecute(exe process.Execute) string {
+	if exe.Command() == "" {
+		return "{}"
+	}
+	var fields []string
+	add := func(key string, value any) {
+		fields = append(fields, key+" = "+formatValue(value))
+	}
+	if exe.Name != "" {
+		add("name", exe.Name)
+	}
+	if len(exe.Args) > 0 {
+		add("args", exe.Args)
+	} else {
+		add("cmd", exe.Cmd)
+	}
+	if exe.Shell != "" {
+		add("shell", exe.Shell)
+	}
+	if exe.ChangeDir != "" {
+		add("dir", exe.ChangeDir)
+	}
+	if exe.Type != "" {
+		add("type", string(exe.Type))
+	}
+	if exe.Schedule != "" {
+		add("schedule", exe.Schedule)
+	}
+	if exe.DelayNext != 0 {
+		add("delay_next", exe.DelayNext)
+	}
+	if len(exe.Diagnostics) > 0 {
+		add("diagnostics", exe.Diagnostics)
+	}
+	return "{" + strings.Join(fields, ", ") + "}"
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configNames are the config file names discovered when no -f is given, in
+// order of preference within a directory.
+var configNames = []string{
+	"refresh.toml",
+	"refresh.yaml",
+	"refresh.yml",
+	"refresh.json",
+	".refresh.toml",
+	".refresh.yaml",
+	".refresh.yml",
+	".refresh.json",
+}
+
+// discoverConfig looks for a config file in dir and then each of its parents,
+// returning the first found so a project config is picked up from any
+// subdirectory. The search stops at the repository root, the first directory
+// holding a .git, so a config outside the project is never picked up.
+func discoverConfig(dir string) (string, bool) {
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	refresh "github.com/atterpac/refresh/engine"
+)
+
+// starter is the config `refresh init` writes for a detected project.
+type starter struct {
+	ecosystem  string
+	watched    []string
+	ignoreDirs []string
+	steps      []refresh.Execute
+}
+
+// runInit implements `refresh init`: it inspects the project directory and
+// writes a starter config for the ecosystem it finds.
+func runInit(args []string) error {
+	flags := flag.NewFlagSet("refresh init", flag.ContinueOnError)
+	dir := flags.String("p", ".", "Project directory to inspect")
+	out := flags.String("o", "refresh.toml", "Config file to write, relative to the project directory")
+	force := flags.Bool("force", false, "Overwrite an existing config file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	path := *out
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(*dir, path)
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+	}
+
+	s := detectProject(*dir)
+	if err := os.WriteFile(path, []byte(s.render()), 0o644); err != nil {
+		return err
+	}
+	slog.Info("wrote starter config", "path", path, "project", s.ecosystem)
+	return nil
+}
+
+// detectProject picks a starter from the marker files in dir. Go, Rust and
+// Node projects are recognised by their manifests; a bare Makefile is driven
+// through make; anything else gets a placeholder to fill in.
+func detectProject(dir string) starter {
+	switch {
+	case exists(dir, "go.mod"):
+		return starter{
+			ecosystem:  "go",
+			watched:    []string{"*.go", "*.mod"},
+			ignoreDirs: []string{".git", "vendor", "tmp"},
+			steps: []refresh.Execute{
+				{Name: "build", Cmd: "go build -o ./tmp/app .", Type: refresh.Blocking, Diagnostics: []string{"go"}},
+				{Name: "app", Cmd: "./tmp/app", Type: refresh.Primary},
+			},
+		}
+	case exists(dir, "Cargo.toml"):
+		bin := cargoPackage(dir)
+		return starter{
+			ecosystem:  "rust",
+			watched:    []string{"*.rs", "*.toml"},
+			ignoreDirs: []string{".git", "target"},
+			steps: []refresh.Execute{
+				{Name: "build", Cmd: "cargo build", Type: refresh.Blocking},
+				{Name: "app", Cmd: "./target/debug/" + bin, Type: refresh.Primary},
+			},
+		}
+	case exists(dir, "package.json"):
+		return nodeStarter(dir)
+	case exists(dir, "Makefile"):
+		targets := makeTargets(dir)
+		s := starter{ecosystem: "make", ignoreDirs: []string{".git"}}
+		if targets["build"] {
+			s.steps = append(s.steps, refresh.Execute{Name: "build", Cmd: "make build", Type: refresh.Blocking})
+		}
+		run := "make"
+		if targets["run"] {
+			run = "make run"
+		}
+		s.steps = append(s.steps, refresh.Execute{Name: "app", Cmd: run, Type: refresh.Primary})
+		return s
+	default:
+		return starter{
+			ecosystem:  "unknown",
+			ignoreDirs: []string{".git"},
+			steps: []refresh.Execute{
+				{Name: "app", Cmd: "echo 'edit refresh.toml to set your build and run commands'", Type: refresh.Primary},
+			},
+		}
+	}
+}
+
+// nodeStarter builds a starter from package.json scripts, run through the
+// package manager whose lockfile is present. A dev script usually brings its
+// own watcher, so start is preferred for the primary.
+func nodeStarter(dir string) starter {
+	pm := "npm"
+	switch {
+	case exists(dir, "pnpm-lock.yaml"):
+		pm = "pnpm"
+	case exists(dir, "yarn.lock"):
+		pm = "yarn"
+	case exists(dir, "bun.lockb"), exists(dir, "bun.lock"):
+		pm = "bun"
+	}
+	var pkg struct {
+		Main    string            `json:"main"`
+		Scripts map[string]string `json:"scripts"`
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "package.json")); err == nil {
+		_ = json.Unmarshal(data, &pkg)
+	}
+
+	s := starter{
+		ecosystem:  "node",
+		watched:    []string{"*.js", "*.mjs", "*.ts", "*.jsx", "*.tsx", "*.json"},
+		ignoreDirs: []string{".git", "node_modules", "dist", "build"},
+	}
+	if _, ok := pkg.Scripts["build"]; ok {
+		build := refresh.Execute{Name: "build", Cmd: pm + " run build", Type: refresh.Blocking}
+		if exists(dir, "tsconfig.json") {
+			build.Diagnostics = []string{"tsc"}
+		}
+		s.steps = append(s.steps, build)
+	}
+	run := "node " + cmp.Or(pkg.Main, "index.js")
+	if _, ok := pkg.Scripts["start"]; ok {
+		run = pm + " run start"
+	} else if _, ok := pkg.Scripts["dev"]; ok {
+		run = pm + " run dev"
+	}
+	s.steps = append(s.steps, refresh.Execute{Name: "app", Cmd: run, Type: refresh.Primary})
+	return s
+}
+
+// cargoPackage returns the package name from Cargo.toml, which is also the
+// name of its default binary.
+func cargoPackage(dir string) string {
+	var manifest struct {
+		Package struct {
+			Name string `toml:"name"`
+		} `toml:"package"`
+	}
+	_, _ = toml.DecodeFile(filepath.Join(dir, "Cargo.toml"), &manifest)
+	return cmp.Or(manifest.Package.Name, filepath.Base(absDir(dir)))
+}
+
+var makeTarget = regexp.MustCompile(`^([A-Za-z0-9_.-]+)\s*:([^=]|$)`)
+
+// makeTargets lists the explicit targets defined in dir's Makefile.
+func makeTargets(dir string) map[string]bool {
+	targets := make(map[string]bool)
+	file, err := os.Open(filepath.Join(dir, "Makefile"))
+	if err != nil {
+		return targets
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if m := makeTarget.FindStringSubmatch(scanner.Text()); m != nil {
+			targets[m[1]] = true
+		}
+	}
+	return targets
+}
+
+// render formats the starter as a commented TOML config.
+func (s starter) render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# refresh config generated by `refresh init` for a %s project.\n", s.ecosystem)
+	b.WriteString("# See https://github.com/atterpac/refresh#config-file for every option.\n\n")
+	b.WriteString("[config]\n")
+	b.WriteString("root_path = \".\"\n")
+	b.WriteString("# debug | info | warn | error | mute\n")
+	b.WriteString("log_level = \"info\"\n")
+	b.WriteString("# Milliseconds of quiet after a change before reloading\n")
+	b.WriteString("debounce = 500\n\n")
+	b.WriteString("[config.ignore]\n")
+	if len(s.watched) > 0 {
+		fmt.Fprintf(&b, "watched_extension = %s\n", tomlList(s.watched))
+	} else {
+		b.WriteString("# watched_extension = [\"*.go\"] # empty watches every file\n")
+	}
+	fmt.Fprintf(&b, "dir = %s\n", tomlList(s.ignoreDirs))
+	b.WriteString("git = true\n")
+	for _, step := range s.steps {
+		b.WriteString("\n[[config.executes]]\n")
+		fmt.Fprintf(&b, "name = %s\n", strconv.Quote(step.Name))
+		fmt.Fprintf(&b, "cmd = %s\n", strconv.Quote(step.Cmd))
+		fmt.Fprintf(&b, "type = %s\n", strconv.Quote(string(step.Type)))
+		if len(step.Diagnostics) > 0 {
+			fmt.Fprintf(&b, "diagnostics = %s\n", tomlList(step.Diagnostics))
+		}
+	}
+	return b.String()
+}
+
+func tomlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func absDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
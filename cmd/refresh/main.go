@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	refresh "github.com/atterpac/refresh/engine"
 )
@@ -22,9 +26,37 @@ type cliFlags struct {
 	version     bool
 	gitIgnore   bool
 	trapSuspend bool
+	strict      bool
+	exitOn      string
+	once        bool
+	oncePrimary bool
+	profile     string
 	ignoreDir   string
 	ignoreFile  string
 	ignoreExt   string
+	// discovered is set when configPath was found by discover rather than
+	// given with -f.
+	discovered bool
+
+	// explicit holds the flags given on the command line, by name, with their
+	// values; only these override a config file.
+	explicit map[string]string
+}
+
+// flagSettings maps the flags that override config values to the
+// refresh.Settings key each one sets.
+var flagSettings = map[string]string{
+	"p":            "root_path",
+	"l":            "log_level",
+	"d":            "debounce",
+	"pause":        "enable_pause",
+	"id":           "ignore.dir",
+	"if":           "ignore.file",
+	"ie":           "ignore.watched_extension",
+	"git":          "ignore.git",
+	"e":            "exec_list",
+	"exit-on":      "exit_on",
+	"once-primary": "once_primary",
 }
 
 // parseFlags parses args (without the program name) into a cliFlags.
@@ -34,7 +66,7 @@ func parseFlags(args []string) (cliFlags, error) {
 	fs.StringVar(&f.rootPath, "p", "./", "Root path to watch")
 	fs.StringVar(&f.execCommand, "e", "", "Comma-separated commands to execute on changes")
 	fs.StringVar(&f.logLevel, "l", "info", "Log level: debug|info|warn|error|mute")
-	fs.StringVar(&f.configPath, "f", "", "Config file to read (.toml or .yaml)")
+	fs.StringVar(&f.configPath, "f", "", "Config file to read (.toml, .yaml or .json)")
 	fs.StringVar(&f.ignoreDir, "id", "", "Ignore directories (comma-separated)")
 	fs.StringVar(&f.ignoreFile, "if", "", "Ignore files (comma-separated)")
 	fs.StringVar(&f.ignoreExt, "ie", "", "Watched extensions (comma-separated)")
@@ -42,12 +74,40 @@ func parseFlags(args []string) (cliFlags, error) {
 	fs.BoolVar(&f.version, "v", false, "Print version")
 	fs.BoolVar(&f.gitIgnore, "git", false, "Read .gitignore in the root")
 	fs.BoolVar(&f.trapSuspend, "pause", false, "Use Ctrl+Z to toggle pause/resume instead of suspending")
+	fs.BoolVar(&f.strict, "strict", false, "Refuse to start if the config file fails validation")
+	fs.StringVar(&f.exitOn, "exit-on", "", "Exit when the primary exits on its own: never|primary-exit|primary-failure")
+	fs.BoolVar(&f.once, "once", false, "Run the steps once without watching, then exit with the first failure's status")
+	fs.BoolVar(&f.oncePrimary, "once-primary", false, "With -once, also run the primary until it exits")
+	fs.StringVar(&f.profile, "profile", "", "Config profile to apply (overrides $"+refresh.ProfileEnv+")")
 	if err := fs.Parse(args); err != nil {
 		return f, err
 	}
+	f.explicit = make(map[string]string)
+	fs.Visit(func(fl *flag.Flag) {
+		f.explicit[fl.Name] = fl.Value.String()
+	})
 	return f, nil
 }
 
+// overrides turns the explicitly set flags, and -profile, into the overrides
+// the engine applies on top of the file and the inherited environment, in
+// every load of the config including hot reloads.
+func (f cliFlags) overrides() refresh.Overrides {
+	o := refresh.Overrides{
+		Profile:            f.profile,
+		Values:             make(map[string]string),
+		Sources:            make(map[string]string),
+		ConfigRelativeRoot: f.discovered,
+	}
+	for name, value := range f.explicit {
+		if key, ok := flagSettings[name]; ok {
+			o.Values[key] = value
+			o.Sources[key] = "flag -" + name
+		}
+	}
+	return o
+}
+
 // splitList splits a comma-separated flag value, trimming whitespace and
 // dropping empty entries (so an unset flag yields nil, not [""]).
 func splitList(csv string) []string {
@@ -71,6 +131,8 @@ func (f cliFlags) toConfig() refresh.Config {
 		LogLevel:    f.logLevel,
 		Debounce:    f.debounce,
 		EnablePause: f.trapSuspend,
+		ExitOn:      refresh.ExitPolicy(f.exitOn),
+		OncePrimary: f.oncePrimary,
 		Ignore: refresh.Ignore{
 			File:         splitList(f.ignoreFile),
 			Dir:          splitList(f.ignoreDir),
@@ -80,23 +142,73 @@ func (f cliFlags) toConfig() refresh.Config {
 	}
 }
 
+// discover fills in configPath from a config file found in the working
+// directory or one of its parents when neither -f nor -e was given. The
+// config's relative root_path then resolves against its directory, so it runs
+// as if refresh had been started next to it; the working directory is left
+// alone.
+func (f *cliFlags) discover() error {
+	if f.configPath != "" || f.execCommand != "" {
+		return nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	path, ok := discoverConfig(wd)
+	if !ok {
+		return nil
+	}
+	slog.Info("using config file", "path", path)
+	f.configPath, f.discovered = path, true
+	return nil
+}
+
 // newEngine builds an engine from a config file when -f is given, otherwise from
-// the individual flags.
+// the individual flags. Either way the REFRESH_* environment variables take
+// precedence over both, and explicitly set flags over everything.
 func newEngine(f cliFlags) (*refresh.Engine, error) {
 	if f.configPath != "" {
-		switch {
-		case strings.HasSuffix(f.configPath, ".toml"):
-			return refresh.NewEngineFromTOML(f.configPath)
-		case strings.HasSuffix(f.configPath, ".yaml"), strings.HasSuffix(f.configPath, ".yml"):
-			return refresh.NewEngineFromYAML(f.configPath)
-		default:
-			return nil, fmt.Errorf("unsupported config file %q (want .toml or .yaml)", f.configPath)
+		for _, ext := range []string{".toml", ".yaml", ".yml", ".json"} {
+			if strings.HasSuffix(f.configPath, ext) {
+				return refresh.NewEngineFromFile(f.configPath, f.overrides())
+			}
 		}
+		return nil, fmt.Errorf("unsupported config file %q (want .toml, .yaml or .json)", f.configPath)
 	}
-	return refresh.NewEngineFromConfig(f.toConfig())
+	cfg := f.toConfig()
+	if err := cfg.ApplyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.ApplyOverrides(f.overrides()); err != nil {
+		return nil, err
+	}
+	return refresh.NewEngineFromConfig(cfg)
+}
+
+// subcommands are the verbs accepted as the first argument; anything else is
+// parsed as flags for the watcher.
+var subcommands = map[string]func(args []string) error{
+	"config":   runConfig,
+	"init":     runInit,
+	"schema":   runSchema,
+	"validate": runValidate,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				if errors.Is(err, flag.ErrHelp) {
+					os.Exit(2)
+				}
+				slog.Error("refresh "+os.Args[1], "err", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	f, err := parseFlags(os.Args[1:])
 	if err != nil {
 		os.Exit(2)
@@ -105,19 +217,55 @@ func main() {
 		fmt.Println(PrintBanner(version))
 		return
 	}
+	if err := f.discover(); err != nil {
+		slog.Error("failed to locate config", "err", err)
+		os.Exit(1)
+	}
+	if f.strict && f.configPath != "" {
+		if err := validateConfig(os.Stderr, f.configPath, f.overrides()); err != nil {
+			slog.Error("config failed strict validation", "err", err)
+			os.Exit(1)
+		}
+	}
 
 	watch, err := newEngine(f)
 	if err != nil {
 		slog.Error("failed to configure refresh", "err", err)
 		os.Exit(1)
 	}
-	// Start blocks until a signal triggers shutdown.
+	if f.once {
+		os.Exit(runOnce(watch))
+	}
+	// Start blocks until a signal triggers shutdown, or the primary exits under
+	// -exit-on, in which case refresh exits with its status.
 	if err := watch.Start(); err != nil {
+		var exit *refresh.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.Code)
+		}
 		slog.Error("refresh exited with error", "err", err)
 		os.Exit(1)
 	}
 }
 
+// runOnce runs the steps a single time for -once, prints the summary, and
+// returns the process exit status: the failing step's exit code, or 0.
+func runOnce(watch *refresh.Engine) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	summary, err := watch.RunOnce(ctx)
+	fmt.Fprint(os.Stderr, summary)
+	var exit *refresh.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return exit.Code
+	case err != nil:
+		slog.Error("refresh run failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
 func PrintBanner(ver string) string {
 	return fmt.Sprintf(`
    ___  ___________  __________ __
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+
+	refresh "github.com/atterpac/refresh/engine"
 )
 
 func TestParseFlagsToConfig(t *testing.T) {
@@ -55,7 +60,7 @@ func TestSplitListDropsEmpties(t *testing.T) {
 }
 
 func TestNewEngineRejectsUnsupportedConfigExtension(t *testing.T) {
-	if _, err := newEngine(cliFlags{configPath: "config.json"}); err == nil {
+	if _, err := newEngine(cliFlags{configPath: "config.ini"}); err == nil {
 		t.Fatal("expected error for unsupported config extension")
 	}
 }
@@ -75,3 +80,142 @@ func TestNewEngineFromFlags(t *testing.T) {
 		t.Errorf("executes = %v, want [sleep 1]", execs)
 	}
 }
+
+func TestDiscoverConfigWalksParents(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := discoverConfig(nested); ok {
+		t.Fatal("found a config where there is none")
+	}
+	want := filepath.Join(root, ".refresh.yaml")
+	if err := os.WriteFile(want, []byte("config: {}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := discoverConfig(nested); !ok || got != want {
+		t.Errorf("discoverConfig = %q, %v; want %q", got, ok, want)
+	}
+	// A nearer config wins over one further up.
+	near := filepath.Join(root, "a", "refresh.toml")
+	if err := os.WriteFile(near, []byte(""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := discoverConfig(nested); got != near {
+		t.Errorf("discoverConfig = %q, want the nearer %q", got, near)
+	}
+	// The search ends at the repository root.
+	if err := os.Remove(near); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "a", ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := discoverConfig(nested); ok {
+		t.Errorf("discoverConfig = %q, want nothing above the repository root", got)
+	}
+}
+
+func TestInitWritesLoadableStarter(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		primary string
+	}{
+		{"go", map[string]string{"go.mod": "module x\n"}, "go", "./tmp/app"},
+		{"rust", map[string]string{"Cargo.toml": "[package]\nname = \"svc\"\n"}, "rust", "./target/debug/svc"},
+		{"node", map[string]string{"package.json": `{"scripts":{"build":"tsc","start":"node ."}}`, "yarn.lock": ""}, "node", "yarn run start"},
+		{"make", map[string]string{"Makefile": "build:\n\tgo build\nrun: build\n\t./app\n"}, "make", "make run"},
+		{"unknown", nil, "unknown", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := runInit([]string{"-p", dir}); err != nil {
+				t.Fatalf("runInit: %v", err)
+			}
+			if s := detectProject(dir); s.ecosystem != tt.want {
+				t.Errorf("ecosystem = %q, want %q", s.ecosystem, tt.want)
+			}
+			eng, err := refresh.NewEngineFromTOML(filepath.Join(dir, "refresh.toml"))
+			if err != nil {
+				t.Fatalf("generated config does not load: %v", err)
+			}
+			execs := eng.ProcessManager.GetExecutes()
+			if tt.primary != "" && execs[len(execs)-1] != tt.primary {
+				t.Errorf("executes = %v, want primary %q last", execs, tt.primary)
+			}
+			if err := runInit([]string{"-p", dir}); err == nil {
+				t.Error("expected init to refuse to overwrite an existing config")
+			}
+		})
+	}
+}
+
+func TestValidateCommandFailsOnErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresh.toml")
+	if err := os.WriteFile(path, []byte("[config]\nroot_path = \".\"\ncolour = true\n\n[[config.executes]]\ncmd = \"./app\"\ntype = \"primary\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := validateConfig(&out, path, refresh.Overrides{}); err == nil {
+		t.Fatal("expected validation to fail on an unknown key")
+	}
+	if want := path + ":3:1: error: config.colour: unknown key"; !strings.Contains(out.String(), want) {
+		t.Errorf("output = %q, want it to contain %q", out.String(), want)
+	}
+}
+
+// TestExplicitFlagsOverrideConfigFile checks the precedence file < env <
+// explicit flag, that flags left at their defaults do not override the file,
+// and that config print names each value's source.
+func TestExplicitFlagsOverrideConfigFile(t *testing.T) {
+	for _, s := range refresh.Settings {
+		if s.Env != "" {
+			t.Setenv(s.Env, "")
+		}
+	}
+	t.Setenv("REFRESH_LOG_BUFFER", "50")
+	t.Setenv("REFRESH_LOG_LEVEL", "warn")
+	path := filepath.Join(t.TempDir(), "refresh.toml")
+	if err := os.WriteFile(path, []byte("[config]\nroot_path = \".\"\nlog_level = \"info\"\ndebounce = 300\n\n[[config.executes]]\ncmd = \"./app\"\ntype = \"primary\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parseFlags([]string{"-f", path, "-l", "mute"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng, err := newEngine(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := eng.Config
+	if cfg.LogLevel != "mute" || cfg.Debounce != 300 || cfg.LogBuffer != 50 {
+		t.Errorf("log_level %q, debounce %d, log_buffer %d; want mute, 300, 50", cfg.LogLevel, cfg.Debounce, cfg.LogBuffer)
+	}
+	if env := os.Getenv("REFRESH_LOG_LEVEL"); env != "warn" {
+		t.Errorf("REFRESH_LOG_LEVEL = %q; flags must not leak into the environment commands inherit", env)
+	}
+
+	var out strings.Builder
+	if err := printConfig(&out, f, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"mute"`, "# flag -l",
+		"# env REFRESH_LOG_BUFFER",
+		"# file " + path,
+		`executes[0]`, `cmd = "./app"`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("config print output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	refresh "github.com/atterpac/refresh/engine"
+)
+
+// runSchema implements `refresh schema`: it prints the config file JSON Schema,
+// or writes it to -o, for editors to validate and complete config files.
+func runSchema(args []string) error {
+	flags := flag.NewFlagSet("refresh schema", flag.ContinueOnError)
+	out := flags.String("o", "", "File to write the schema to (default stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	schema, err := refresh.JSONSchema()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(schema)
+		return err
+	}
+	return os.WriteFile(*out, schema, 0o644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	refresh "github.com/atterpac/refresh/engine"
+)
+
+// runValidate implements `refresh validate [file]`: it checks a config file
+// (the discovered one when no file is given) and prints every issue with its
+// position. It fails when any issue is an error; warnings alone pass.
+func runValidate(args []string) error {
+	flags := flag.NewFlagSet("refresh validate", flag.ContinueOnError)
+	profile := flags.String("profile", "", "Validate with this config profile applied")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	path := flags.Arg(0)
+	overrides := refresh.Overrides{Profile: *profile}
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		found, ok := discoverConfig(wd)
+		if !ok {
+			return errors.New("no config file found; pass one as an argument")
+		}
+		path, overrides.ConfigRelativeRoot = found, true
+	}
+	return validateConfig(os.Stdout, path, overrides)
+}
+
+// validateConfig prints the issues found in path, loaded with overrides, to w
+// and returns an error when any of them is an error. Used by `refresh
+// validate` and -strict.
+func validateConfig(w io.Writer, path string, overrides refresh.Overrides) error {
+	issues, err := refresh.ValidateConfigFileWith(path, overrides)
+	if err != nil {
+		return err
+	}
+	errs := 0
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+		if issue.Severity == refresh.SeverityError {
+			errs++
+		}
+	}
+	if errs > 0 {
+		return fmt.Errorf("%s: %d error(s)", path, errs)
+	}
+	if len(issues) == 0 {
+		fmt.Fprintf(w, "%s: ok\n", path)
+	}
+	return nil
+}
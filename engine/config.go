@@ -3,42 +3,105 @@ package engine
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/atterpac/refresh/process"
-	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	RootPath         string            `toml:"root_path"  yaml:"root_path"`
-	BackgroundStruct process.Execute   `toml:"background" yaml:"background"`
-	Ignore           Ignore            `toml:"ignore"     yaml:"ignore"`
-	ExecStruct       []process.Execute `toml:"executes"   yaml:"executes"`
-	ExecList         []string          `toml:"exec_list"  yaml:"exec_list"`
-	LogLevel         string            `toml:"log_level"  yaml:"log_level"`
-	Debounce         int               `toml:"debounce"   yaml:"debounce"`
+	RootPath         string            `toml:"root_path"  yaml:"root_path"  json:"root_path"`
+	BackgroundStruct process.Execute   `toml:"background" yaml:"background" json:"background"`
+	Ignore           Ignore            `toml:"ignore"     yaml:"ignore"     json:"ignore"`
+	ExecStruct       []process.Execute `toml:"executes"   yaml:"executes"   json:"executes"`
+	ExecList         []string          `toml:"exec_list"  yaml:"exec_list"  json:"exec_list"`
+	LogLevel         string            `toml:"log_level"  yaml:"log_level"  json:"log_level"`
+	Debounce         int               `toml:"debounce"   yaml:"debounce"   json:"debounce"`
 	// EnablePause, when true, repurposes the terminal suspend key (Ctrl+Z /
 	// SIGTSTP) as a pause/resume toggle: the first press pauses reloads, the next
 	// resumes. This overrides the shell's normal "suspend to background" behavior,
 	// so it is opt-in. No-op on platforms without SIGTSTP (Windows).
-	EnablePause bool `toml:"enable_pause" yaml:"enable_pause"`
-	Callback    func(*EventCallback) EventHandle
-	Slog        *slog.Logger
+	EnablePause bool                             `toml:"enable_pause" yaml:"enable_pause" json:"enable_pause"`
+	Callback    func(*EventCallback) EventHandle `json:"-"`
+	Slog        *slog.Logger                     `json:"-"`
 
 	// Output, when set, taps each process's stdout/stderr: it is called once per
 	// stream when a process starts and returns the io.Writer that stream is wired
 	// to. Returning nil keeps the default (the process's own os.Stdout/os.Stderr).
 	// An upstream TUI returns a per-process buffer here to render separated logs.
-	Output process.OutputFunc
+	Output process.OutputFunc `json:"-"`
 
 	// OnProcessEvent, when set, receives a ProcessEvent on every process state
 	// transition (running, exited, failed, killed). It is called synchronously
 	// from the supervising goroutine and must not block.
-	OnProcessEvent process.EventFunc
+	OnProcessEvent process.EventFunc `json:"-"`
+
+	// OnReload, when set, receives a ReloadEvent as each reload cycle starts,
+	// succeeds, fails, or is deferred while paused — with its cycle number,
+	// trigger, changed paths, duration and failing step. Like OnProcessEvent it
+	// is called synchronously from the supervisor goroutine and must not block.
+	OnReload ReloadFunc `json:"-"`
+
+	// LogBuffer, when > 0, keeps the last LogBuffer lines of each process's stdout
+	// and stderr in memory, queryable through Engine.Logs and Engine.FollowLogs.
+	// Zero (the default) disables buffering.
+	LogBuffer int `toml:"log_buffer" yaml:"log_buffer" json:"log_buffer"`
+
+	// MetricsInterval, when > 0, samples the CPU and memory use of every running
+	// process every MetricsInterval milliseconds, into ProcessInfo.Metrics and
+	// as KindMetrics events. Linux only; zero (the default) disables sampling.
+	MetricsInterval int `toml:"metrics_interval" yaml:"metrics_interval" json:"metrics_interval"`
+
+	// OnMetrics, when set, receives a process's snapshot each time its metrics
+	// are sampled. It is called from the sampling goroutine and must not block.
+	OnMetrics process.MetricsFunc `json:"-"`
+
+	// Strict, when set in a config file, makes loading it fail on anything
+	// ValidateConfigFile reports as an error — unknown keys included — instead
+	// of silently ignoring it. It applies to hot reloads of the file too.
+	Strict bool `toml:"strict" yaml:"strict" json:"strict"`
+
+	// Extends names a config file this one is layered on, relative to this
+	// file's directory. Keys set here override the base; executes and profiles
+	// are merged by name.
+	Extends string `toml:"extends" yaml:"extends" json:"extends"`
+	// Profile selects one of Profiles when $REFRESH_PROFILE is unset.
+	Profile string `toml:"profile" yaml:"profile" json:"profile"`
+	// Profiles are named overrides of this config, applied when selected.
+	Profiles map[string]Profile `toml:"profiles" yaml:"profiles" json:"profiles"`
+
+	// Proxy serves a live-reload reverse proxy in front of the primary; see
+	// ProxyConfig. Off unless proxy.listen is set.
+	Proxy ProxyConfig `toml:"proxy" yaml:"proxy" json:"proxy"`
+
+	// OncePrimary makes RunOnce also run the primary process and wait for it to
+	// exit, as for a test runner; by default RunOnce skips the primary.
+	OncePrimary bool `toml:"once_primary" yaml:"once_primary" json:"once_primary"`
+
+	// ExitOn stops the engine when the primary process exits on its own:
+	// "primary-exit" on any exit, "primary-failure" on a non-zero status or a
+	// signal. Start then returns an *ExitError with the primary's exit code.
+	// "never" (or empty), the default, keeps watching.
+	ExitOn ExitPolicy `toml:"exit_on" yaml:"exit_on" json:"exit_on"`
+
+	// Shell runs the command strings of executes that do not set their own
+	// shell, e.g. "bash -lc" for a login shell's PATH. Empty uses /bin/sh -c
+	// (cmd /C on Windows).
+	Shell string `toml:"shell" yaml:"shell" json:"shell"`
+
+	// Hooks are commands, or Go functions, run around reloads, primary crashes
+	// and shutdown; see Hooks.
+	Hooks Hooks `toml:"hooks" yaml:"hooks" json:"hooks"`
+
+	// sources records where each setting's value came from; see Source.
+	sources map[string]string
 }
 
 func DefaultEngineConfig() Config {
@@ -107,31 +170,17 @@ func (c *Config) WithExecuteCommand(cmd process.Execute) *Config {
 }
 
 // Reads a config.toml file and returns the engine
-func (engine *Engine) readConfigFile(path string) (*Engine, error) {
-	if _, err := toml.DecodeFile(path, &engine); err != nil {
-		slog.Error("reading config file", "path", path, "err", err)
-		return nil, err
-	}
-	return engine, nil
-}
-
-func (engine *Engine) readConfigYaml(path string) (*Engine, error) {
-	file, err := os.ReadFile(path)
-	if err != nil {
+// readConfig loads the config file at path into the engine.
+func (engine *Engine) readConfig(path string, decode decoder) error {
+	if err := engine.loadFile(path, decode); err != nil {
 		slog.Error("reading config file", "path", path, "err", err)
-		return nil, err
-	}
-	err = yaml.Unmarshal(file, &engine)
-	if err != nil {
-		slog.Error("parsing yaml config", "path", path, "err", err)
-		return nil, err
+		return err
 	}
-	return engine, nil
+	return nil
 }
 
 func (engine *Engine) StringtoConfigYAML(yamlString string) error {
-	err := yaml.Unmarshal([]byte(yamlString), &engine)
-	if err != nil {
+	if err := engine.loadDocument([]byte(yamlString), decodeYAML, workingDir(), nil); err != nil {
 		slog.Error("parsing yaml config string", "err", err)
 		return err
 	}
@@ -139,13 +188,21 @@ func (engine *Engine) StringtoConfigYAML(yamlString string) error {
 }
 
 func (engine *Engine) StringtoConfigTOML(tomlString string) error {
-	if _, err := toml.Decode(tomlString, &engine); err != nil {
+	if err := engine.loadDocument([]byte(tomlString), decodeTOML, workingDir(), nil); err != nil {
 		slog.Error("parsing toml config string", "err", err)
 		return err
 	}
 	return nil
 }
 
+func (engine *Engine) StringtoConfigJSON(jsonString string) error {
+	if err := engine.loadDocument([]byte(jsonString), decodeJSON, workingDir(), nil); err != nil {
+		slog.Error("parsing json config string", "err", err)
+		return err
+	}
+	return nil
+}
+
 // Verify required data is present in config
 func (engine *Engine) verifyConfig() error {
 	slog.Debug("verifying config")
@@ -157,6 +214,14 @@ func (engine *Engine) verifyConfig() error {
 	if t := engine.Config.BackgroundStruct.Type; t != "" && t != process.Background {
 		slog.Warn("background.type is ignored; the background command always runs as a background process", "ignored_type", t)
 	}
+	if !engine.Config.ExitOn.valid() {
+		return fmt.Errorf("invalid exit_on %q (want never, primary-exit or primary-failure)", engine.Config.ExitOn)
+	}
+	if engine.Config.Proxy.Listen != "" {
+		if _, err := proxyTarget(engine.Config.Proxy.Target); err != nil {
+			return err
+		}
+	}
 	engine.normalizeExecutes()
 	if err := engine.verifyExecute(); err != nil {
 		return err
@@ -216,8 +281,9 @@ func hasPrimary(specs []process.Execute) bool {
 	return false
 }
 
-// verifyExecute ensures at least one execute is configured and that no more than
-// one primary process is declared.
+// verifyExecute ensures at least one execute is configured, that no more than
+// one primary process is declared, and that every diagnostic parser named in
+// the config exists.
 func (engine *Engine) verifyExecute() error {
 	if len(engine.Config.ExecStruct) == 0 {
 		return errors.New("at least one execute must be provided via ExecStruct or ExecList")
@@ -227,6 +293,9 @@ func (engine *Engine) verifyExecute() error {
 		if exe.Type == process.Primary {
 			primary++
 		}
+		if err := verifySpec(exe); err != nil {
+			return err
+		}
 	}
 	if primary > 1 {
 		return errors.New("only one primary execute can be set")
@@ -264,19 +333,71 @@ func readGitIgnore(path string) []string {
 	return patterns
 }
 
-func (e *Engine) generateProcess() {
+// verifySpec checks the parts of a single execute that can be validated on
+// their own: that it does not set both cmd and args, its restart strategy,
+// that no port is both waited for and handed off, resource limits, a periodic
+// task's schedule and its diagnostic parser names.
+func verifySpec(exe process.Execute) error {
+	if exe.Cmd != "" && len(exe.Args) > 0 {
+		return fmt.Errorf("%s: cmd and args are mutually exclusive", specName(exe))
+	}
+	switch exe.RestartStrategy {
+	case "", process.StopFirst, process.StartFirst:
+	default:
+		return fmt.Errorf("%s: invalid restart strategy %q (want stop-first or start-first)", specName(exe), exe.RestartStrategy)
+	}
+	if port := listenedPort(exe); port != 0 {
+		return fmt.Errorf("%s: port %d is in both ports and listen", specName(exe), port)
+	}
+	if err := exe.Limits.Validate(); err != nil {
+		return fmt.Errorf("%s: limits: %w", specName(exe), err)
+	}
+	if exe.Type == process.Periodic {
+		if _, err := process.ParseSchedule(exe.Schedule); err != nil {
+			return fmt.Errorf("%s: %w", specName(exe), err)
+		}
+	}
+	for _, name := range exe.Diagnostics {
+		if _, err := process.LookupParser(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listenedPort returns a port of exe's Ports that one of its Listen addresses
+// also binds, or 0. Refresh holds Listen sockets open across restarts, so
+// waiting for such a port to be free always times out.
+func listenedPort(exe process.Execute) int {
+	for _, addr := range exe.Listen {
+		_, portStr, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		if port, err := strconv.Atoi(portStr); err == nil && slices.Contains(exe.Ports, port) {
+			return port
+		}
+	}
+	return 0
+}
+
+// generateProcess wires the process manager to the engine and adds the
+// configured processes, failing on the first it rejects.
+func (e *Engine) generateProcess() error {
 	// Wire the observability hooks before any process is added so snapshots and
 	// events are available for the whole lifecycle.
 	e.ProcessManager.Output = e.Config.Output
-	e.ProcessManager.OnEvent = e.Config.OnProcessEvent
+	e.ProcessManager.OnEvent = e.onProcessEvent
+	e.ProcessManager.LogLines = e.Config.LogBuffer
+	e.ProcessManager.MetricsInterval = time.Duration(e.Config.MetricsInterval) * time.Millisecond
+	e.ProcessManager.OnMetrics = e.onMetrics
 
 	// A configured background command is started once at startup, survives
 	// reloads, and is killed on shutdown — regardless of any Type set on it.
-	if bg := e.Config.BackgroundStruct; bg.Cmd != "" {
-		bg.Type = process.Background
-		_ = e.ProcessManager.AddProcessSpec(bg)
-	}
-	for _, ex := range e.Config.ExecStruct {
-		_ = e.ProcessManager.AddProcessSpec(ex)
+	for _, ex := range configSpecs(e.Config) {
+		if err := e.ProcessManager.AddProcessSpec(ex); err != nil {
+			return err
+		}
 	}
+	return nil
 }
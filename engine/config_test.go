@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/atterpac/refresh/process"
@@ -51,7 +52,7 @@ func TestExecListToSpecs(t *testing.T) {
 				t.Fatalf("got %d specs, want %d: %+v", len(got), len(tt.want), got)
 			}
 			for i := range tt.want {
-				if got[i] != tt.want[i] {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
 					t.Errorf("spec[%d] = %+v, want %+v", i, got[i], tt.want[i])
 				}
 			}
@@ -40,6 +40,20 @@ config:
       type: primary
 `
 
+const jsonConfig = `{
+  "$schema": "./refresh.schema.json",
+  "config": {
+    "root_path": ".",
+    "log_level": "warn",
+    "debounce": 250,
+    "ignore": {"watched_extension": ["*.go"], "dir": ["vendor"]},
+    "executes": [
+      {"cmd": "go build -o ./app", "type": "blocking"},
+      {"cmd": "./app", "type": "primary"}
+    ]
+  }
+}`
+
 func assertLoaded(t *testing.T, eng *Engine) {
 	t.Helper()
 	if eng.Config.LogLevel != "warn" {
@@ -78,6 +92,18 @@ func TestNewEngineFromYAML(t *testing.T) {
 	assertLoaded(t, eng)
 }
 
+func TestNewEngineFromJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresh.json")
+	if err := os.WriteFile(path, []byte(jsonConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng, err := NewEngineFromJSON(path)
+	if err != nil {
+		t.Fatalf("NewEngineFromJSON: %v", err)
+	}
+	assertLoaded(t, eng)
+}
+
 // TestStringToConfigTOML guards the fix for the bug where StringtoConfigTOML
 // decoded TOML with the YAML unmarshaler (which would leave the config empty).
 func TestStringToConfigTOML(t *testing.T) {
@@ -105,3 +131,16 @@ func TestStringToConfigYAML(t *testing.T) {
 		t.Errorf("Debounce = %d, want 7", e.Config.Debounce)
 	}
 }
+
+func TestStringToConfigJSON(t *testing.T) {
+	e := &Engine{}
+	if err := e.StringtoConfigJSON(`{"config": {"root_path": "from-json", "debounce": 9}}`); err != nil {
+		t.Fatalf("StringtoConfigJSON: %v", err)
+	}
+	if e.Config.RootPath != "from-json" {
+		t.Errorf("RootPath = %q, want from-json", e.Config.RootPath)
+	}
+	if e.Config.Debounce != 9 {
+		t.Errorf("Debounce = %d, want 9", e.Config.Debounce)
+	}
+}
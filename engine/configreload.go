@@ -0,0 +1,243 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+	"github.com/rjeczalik/notify"
+)
+
+// configSettle is how long the config file must be quiet before it is re-read.
+// Editors often save in several steps (truncate, write, rename), and reading
+// mid-save would see a half-written file.
+const configSettle = 100 * time.Millisecond
+
+// watchConfig watches the directories holding the config files, the engine's
+// and those it extends (watching a directory rather than a file survives
+// editors that save by renaming a temporary over it), and signals the
+// supervisor once an edit to any of them settles. It replaces any previous
+// watch. Called only from the supervisor goroutine.
+func (engine *Engine) watchConfig(ctx context.Context, files []string) error {
+	if engine.stopConfigWatch != nil {
+		engine.stopConfigWatch()
+		engine.stopConfigWatch = nil
+	}
+	events := make(chan notify.EventInfo, 8)
+	watched, dirs := make(map[string]bool), make(map[string]bool)
+	for i, file := range files {
+		watched[file] = true
+		// Events may report the path with its symlinks resolved.
+		if resolved, err := filepath.EvalSymlinks(file); err == nil {
+			watched[resolved] = true
+		}
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		if err := notify.Watch(dir, events, notify.All); err != nil {
+			// An extended file may name a directory that does not exist yet.
+			if i > 0 {
+				slog.Debug("not watching extended config file", "path", file, "err", err)
+				continue
+			}
+			notify.Stop(events)
+			return err
+		}
+		dirs[dir] = true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	engine.stopConfigWatch = cancel
+	go func() {
+		defer notify.Stop(events)
+		timer := time.NewTimer(0)
+		if !timer.Stop() {
+			<-timer.C
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case ei := <-events:
+				if watched[ei.Path()] {
+					timer.Reset(configSettle)
+				}
+			case <-timer.C:
+				nonBlockingSend(engine.configCh)
+			}
+		}
+	}()
+	slog.Debug("watching config files", "paths", files)
+	return nil
+}
+
+// loadConfigFile reads and verifies a config file with the given overrides
+// without touching the running engine, choosing the decoder from the file
+// extension. It also returns the files loaded: path and those it extends.
+func loadConfigFile(path string, overrides Overrides) (Config, []string, error) {
+	next := &Engine{overrides: overrides}
+	if err := next.readConfig(path, decoderFor(path)); err != nil {
+		return Config{}, next.loaded, err
+	}
+	if next.Config.Strict {
+		if err := validateStrict(path, overrides); err != nil {
+			return Config{}, next.loaded, err
+		}
+	}
+	if err := next.verifyConfig(); err != nil {
+		return Config{}, next.loaded, err
+	}
+	return next.Config, next.loaded, nil
+}
+
+// reloadConfig re-reads the config file after it changed and applies it. An
+// unreadable or invalid file is reported and the running config is kept. Called
+// only from the supervisor goroutine.
+func (engine *Engine) reloadConfig(ctx context.Context) {
+	next, files, err := loadConfigFile(engine.configPath, engine.overrides)
+	// Follow a changed extends chain even when it does not load yet, so fixing
+	// a newly extended file is picked up.
+	if files != nil && !slices.Equal(files, *engine.configFiles.Load()) {
+		engine.configFiles.Store(&files)
+		if err := engine.watchConfig(ctx, files); err != nil {
+			slog.Warn("not watching config files for changes", "paths", files, "err", err)
+		}
+	}
+	if err != nil {
+		slog.Error("config file invalid, keeping current config", "path", engine.configPath, "err", err)
+		return
+	}
+	engine.applyConfig(ctx, next)
+}
+
+// applyConfig brings the running engine in line with a newly loaded config:
+// log level, ignore rules and debounce take effect immediately, and processes
+// are added, removed or updated according to how their spec changed — a process
+// whose spec is unchanged keeps running untouched. Settings only supplied
+// programmatically (callbacks, hooks, loggers) are kept. Called only from the
+// supervisor goroutine.
+func (engine *Engine) applyConfig(ctx context.Context, next Config) {
+	cur := &engine.Config
+	if next.RootPath != cur.RootPath {
+		slog.Warn("root_path changes take effect when refresh is restarted", "root_path", cur.RootPath)
+	}
+	if next.Proxy != cur.Proxy {
+		slog.Warn("proxy changes take effect when refresh is restarted")
+	}
+	if next.EnablePause != cur.EnablePause {
+		slog.Warn("enable_pause changes take effect when refresh is restarted")
+	}
+	if next.MetricsInterval != cur.MetricsInterval {
+		slog.Warn("metrics_interval changes take effect when refresh is restarted")
+	}
+	if next.LogLevel != cur.LogLevel {
+		engine.SetLogLevel(next.LogLevel)
+		cur.LogLevel = next.LogLevel
+	}
+
+	if next.Ignore.IgnoreGit {
+		next.Ignore.gitPatterns = readGitIgnore(cur.RootPath)
+	}
+	cur.Ignore = next.Ignore
+	cur.Debounce = next.Debounce
+	engine.storeWatchRules()
+
+	cur.ExitOn = next.ExitOn
+	cur.LogBuffer = next.LogBuffer
+	engine.ProcessManager.SetLogLines(next.LogBuffer)
+
+	removed, updated, added := diffSpecs(configSpecs(*cur), configSpecs(next))
+	pm := engine.ProcessManager
+	for _, name := range removed {
+		if err := pm.Remove(name); err != nil {
+			slog.Warn("config reload: removing process", "name", name, "err", err)
+		}
+	}
+	for _, spec := range updated {
+		if err := pm.Update(ctx, specName(spec), spec); err != nil {
+			slog.Warn("config reload: updating process", "name", specName(spec), "err", err)
+		}
+	}
+	for _, spec := range added {
+		if err := pm.Add(ctx, spec); err != nil {
+			slog.Warn("config reload: adding process", "name", specName(spec), "err", err)
+		}
+	}
+	order := make([]string, 0, len(next.ExecStruct)+1)
+	for _, spec := range configSpecs(next) {
+		order = append(order, specName(spec))
+	}
+	pm.Reorder(order)
+
+	cur.Shell = next.Shell
+	// Hook functions can only be set in code, so they outlive the file's hooks.
+	for i, hook := range next.Hooks.all() {
+		hook.Func = cur.Hooks.all()[i].Func
+	}
+	cur.Hooks = next.Hooks
+	cur.BackgroundStruct = next.BackgroundStruct
+	cur.ExecStruct = next.ExecStruct
+	cur.ExecList = next.ExecList
+	cur.sources = next.sources
+	slog.Info("config reloaded", "path", engine.configPath,
+		"added", len(added), "removed", len(removed), "updated", len(updated))
+}
+
+// configSpecs lists the processes a config declares, in cycle order: the
+// background command (always a background process) first, then the executes.
+// Specs without a shell of their own get the config's.
+func configSpecs(cfg Config) []process.Execute {
+	specs := make([]process.Execute, 0, len(cfg.ExecStruct)+1)
+	if bg := cfg.BackgroundStruct; bg.Command() != "" {
+		bg.Type = process.Background
+		specs = append(specs, bg)
+	}
+	specs = append(specs, cfg.ExecStruct...)
+	for i := range specs {
+		if specs[i].Shell == "" && len(specs[i].Args) == 0 {
+			specs[i].Shell = cfg.Shell
+		}
+	}
+	return specs
+}
+
+// specName is the name a spec's process is known by: its Name, or its command
+// when unnamed.
+func specName(spec process.Execute) string {
+	if spec.Name == "" {
+		return spec.Command()
+	}
+	return spec.Name
+}
+
+// diffSpecs compares two process lists by name and reports the names that
+// disappeared, the specs that changed, and the specs that are new.
+func diffSpecs(old, next []process.Execute) (removed []string, updated, added []process.Execute) {
+	prev := make(map[string]process.Execute, len(old))
+	for _, spec := range old {
+		prev[specName(spec)] = spec
+	}
+	seen := make(map[string]bool, len(next))
+	for _, spec := range next {
+		name := specName(spec)
+		seen[name] = true
+		was, ok := prev[name]
+		switch {
+		case !ok:
+			added = append(added, spec)
+		case !reflect.DeepEqual(was, spec):
+			updated = append(updated, spec)
+		}
+	}
+	for _, spec := range old {
+		if name := specName(spec); !seen[name] {
+			removed = append(removed, name)
+		}
+	}
+	return removed, updated, added
+}
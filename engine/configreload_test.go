@@ -0,0 +1,152 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+const reloadTOML = `
+[config]
+root_path = %q
+log_level = "mute"
+debounce = %d
+
+[config.ignore]
+dir = [%q]
+
+[[config.executes]]
+name = "queue"
+cmd = "sleep 31"
+type = "background"
+
+[[config.executes]]
+name = "server"
+cmd = %q
+type = "primary"
+`
+
+// TestConfigFileHotReload edits a running engine's config file: a changed spec
+// restarts just that process, an unchanged one keeps its pid, ignore rules and
+
+// TestExtendedConfigHotReload checks an edit to a file the config extends,
+// in another directory, reloads the config too.
+func TestExtendedConfigHotReload(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(t.TempDir(), "base.toml")
+	writeBase := func(cmd string) {
+		t.Helper()
+		content := fmt.Sprintf("[[config.executes]]\nname = \"server\"\ncmd = %q\ntype = \"primary\"\n", cmd)
+		if err := os.WriteFile(base, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeBase("sleep 30")
+	path := filepath.Join(t.TempDir(), "refresh.toml")
+	content := fmt.Sprintf("[config]\nextends = %q\nroot_path = %q\nlog_level = \"mute\"\n", base, root)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	eng, err := NewEngineFromTOML(path)
+	if err != nil {
+		t.Fatalf("NewEngineFromTOML: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	if !waitFor(func() bool { return serverPID(eng, "server") > 0 }) {
+		t.Fatal("server never started")
+	}
+
+	writeBase("sleep 33")
+	if !waitFor(func() bool {
+		info, ok := processInfo(eng, "server")
+		return ok && info.Exec == "sleep 33" && info.PID > 0
+	}) {
+		t.Errorf("editing the extended file did not reload the config; snapshot = %+v", eng.Processes())
+	}
+}
+
+// debounce apply live, and an invalid edit leaves everything as it was.
+func TestConfigFileHotReload(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(t.TempDir(), "refresh.toml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(fmt.Sprintf(reloadTOML, root, 100, "vendor", "sleep 30"))
+
+	eng, err := NewEngineFromTOML(path)
+	if err != nil {
+		t.Fatalf("NewEngineFromTOML: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = eng.Run(ctx) }()
+	if !waitFor(func() bool { return serverPID(eng, "server") > 0 && serverPID(eng, "queue") > 0 }) {
+		t.Fatal("processes never started")
+	}
+	queuePID, serverOld := serverPID(eng, "queue"), serverPID(eng, "server")
+
+	write(fmt.Sprintf(reloadTOML, root, 250, "build", "sleep 32"))
+	if !waitFor(func() bool {
+		info, ok := processInfo(eng, "server")
+		return ok && info.Exec == "sleep 32" && info.PID > 0 && info.PID != serverOld
+	}) {
+		t.Fatalf("changed primary was not restarted; snapshot = %+v", eng.Processes())
+	}
+	if got := serverPID(eng, "queue"); got != queuePID {
+		t.Errorf("unchanged background process restarted: pid %d -> %d", queuePID, got)
+	}
+	rules := eng.rules.Load()
+	if rules.debounce != 250*time.Millisecond || !slices.Equal(rules.ignore.Dir, []string{"build"}) {
+		t.Errorf("watch rules = %+v, want debounce 250ms and ignore dir build", rules)
+	}
+
+	serverNew := serverPID(eng, "server")
+	write("[config\nroot_path = ")
+	time.Sleep(400 * time.Millisecond)
+	if got := serverPID(eng, "server"); got != serverNew {
+		t.Errorf("invalid config changed the running primary: pid %d -> %d", serverNew, got)
+	}
+	if got := eng.rules.Load().debounce; got != 250*time.Millisecond {
+		t.Errorf("invalid config changed debounce to %v", got)
+	}
+}
+
+func TestDiffSpecs(t *testing.T) {
+	old := []Execute{
+		{Name: "build", Cmd: "go build", Type: Blocking},
+		{Cmd: "./app", Type: Primary},
+		{Name: "queue", Cmd: "sleep 30", Type: Background},
+	}
+	next := []Execute{
+		{Name: "build", Cmd: "go build -race", Type: Blocking},
+		{Cmd: "./app", Type: Primary},
+		{Name: "worker", Cmd: "sleep 30", Type: Background},
+	}
+	removed, updated, added := diffSpecs(old, next)
+	if !slices.Equal(removed, []string{"queue"}) {
+		t.Errorf("removed = %v, want [queue]", removed)
+	}
+	if len(updated) != 1 || updated[0].Cmd != "go build -race" {
+		t.Errorf("updated = %+v, want the build step", updated)
+	}
+	if len(added) != 1 || added[0].Name != "worker" {
+		t.Errorf("added = %+v, want worker", added)
+	}
+}
@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"log/slog"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// maxSummaryDiagnostics caps how many diagnostics the failed-cycle summary
+// prints; the full list stays available through Engine.Diagnostics.
+const maxSummaryDiagnostics = 10
+
+// Diagnostics returns the structured diagnostics parsed from the step that
+// failed the most recent cycle, or nil when that cycle succeeded. Only steps
+// with parsers configured (Execute.Diagnostics / Execute.Parsers) produce any.
+// Safe to call from any goroutine.
+func (engine *Engine) Diagnostics() []process.Diagnostic {
+	return engine.ProcessManager.Diagnostics()
+}
+
+// summarizeDiagnostics logs a compact summary of the failed cycle's
+// diagnostics, so the errors that matter aren't lost in scrolled build output.
+func (engine *Engine) summarizeDiagnostics() {
+	diags := engine.ProcessManager.Diagnostics()
+	if len(diags) == 0 {
+		return
+	}
+	slog.Error("build failed", "process", diags[0].Process, "diagnostics", len(diags))
+	for i, d := range diags {
+		if i == maxSummaryDiagnostics {
+			slog.Error("more diagnostics omitted", "count", len(diags)-i)
+			break
+		}
+		slog.Error(d.String())
+	}
+}
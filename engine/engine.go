@@ -7,6 +7,8 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"syscall"
 
@@ -14,8 +16,8 @@ import (
 )
 
 type Engine struct {
-	Config         Config `toml:"config" yaml:"config"`
-	ProcessManager *process.ProcessManager
+	Config         Config                  `toml:"config" yaml:"config" json:"config"`
+	ProcessManager *process.ProcessManager `json:"-"`
 	ctx            context.Context
 	cancel         context.CancelFunc
 	log            *dynamicLogger
@@ -29,6 +31,48 @@ type Engine struct {
 	reloadCh chan struct{}
 	wakeCh   chan struct{}
 	paused   atomic.Bool
+
+	// pending accumulates the trigger and changed paths of a requested reload
+	// until the supervisor takes it; guarded by pendingMu since producers run on
+	// any goroutine. cycle is the next reload cycle number, owned by the
+	// supervisor.
+	pendingMu sync.Mutex
+	pending   reloadBatch
+	cycle     int
+
+	// events fans process and reload events out to Subscribe streams.
+	events eventHub
+
+	// controlCh carries runtime lifecycle operations (AddProcess and friends) to
+	// the supervisor loop. supervising is set while that loop owns the process
+	// manager; until then operations apply directly. stopped is set once it has
+	// exited, after which they fail.
+	controlCh   chan controlRequest
+	supervising atomic.Bool
+	stopped     atomic.Bool
+
+	// configPath is the absolute path of the config file the engine was built
+	// from, empty for NewEngineFromConfig. The file is watched while running and
+	// configCh signals the supervisor to re-read it. rules holds the ignore rules
+	// and debounce the watcher applies, swapped when the file changes.
+	configPath string
+	configCh   chan struct{}
+	rules      atomic.Pointer[watchRules]
+	// overrides are applied on top of the config file each time it is loaded;
+	// see NewEngineFromFile.
+	overrides Overrides
+	// configFiles are the absolute paths of the config file and every file it
+	// extends, as last loaded: all of them are watched, and none is a source
+	// change. loaded collects them while a file is being loaded, and
+	// stopConfigWatch stops watching them; both are owned by the loading or
+	// supervisor goroutine.
+	configFiles     atomic.Pointer[[]string]
+	loaded          []string
+	stopConfigWatch func()
+
+	// exitCh carries a primary that exited on its own to the supervisor, which
+	// stops the engine if Config.ExitOn says so.
+	exitCh chan process.ProcessEvent
 }
 
 // initControl allocates the control-plane channels. Called by every constructor
@@ -38,6 +82,9 @@ type Engine struct {
 func (engine *Engine) initControl() {
 	engine.reloadCh = make(chan struct{}, 1)
 	engine.wakeCh = make(chan struct{}, 1)
+	engine.controlCh = make(chan controlRequest)
+	engine.configCh = make(chan struct{}, 1)
+	engine.exitCh = make(chan process.ProcessEvent, 1)
 }
 
 // nonBlockingSend pokes a single-slot signal channel without ever blocking the
@@ -57,13 +104,15 @@ func nonBlockingSend(ch chan struct{}) {
 // exactly as a file change would. Honors pause: if the engine is paused the
 // reload is deferred and applied on Resume. Safe to call from any goroutine.
 func (engine *Engine) Reload() {
-	nonBlockingSend(engine.reloadCh)
+	engine.requestReload(TriggerReload, nil)
 }
 
 // Pause suspends reload handling. File changes (and Reload calls) made while
-// paused are remembered and applied on the next Resume. Idempotent.
+// paused are remembered and applied on the next Resume. Periodic tasks skip
+// their runs until then. Idempotent.
 func (engine *Engine) Pause() {
 	if engine.paused.CompareAndSwap(false, true) {
+		engine.ProcessManager.SetPaused(true)
 		slog.Warn("refresh paused — changes deferred until resumed")
 	}
 }
@@ -72,6 +121,7 @@ func (engine *Engine) Pause() {
 // paused. Idempotent.
 func (engine *Engine) Resume() {
 	if engine.paused.CompareAndSwap(true, false) {
+		engine.ProcessManager.SetPaused(false)
 		slog.Warn("refresh resumed")
 		nonBlockingSend(engine.wakeCh)
 	}
@@ -129,7 +179,9 @@ func (engine *Engine) EnableLogs() {
 // Start runs the initial process pass, begins watching the filesystem, and then
 // blocks on a single supervisor loop that serializes reloads and shutdown. It
 // returns nil on a clean (signal-triggered) exit, or an error if the initial
-// startup fails.
+// startup fails. When Config.ExitOn stops the engine after the primary exits,
+// the error is an *ExitError carrying the primary's exit code (0 for a clean
+// exit under primary-exit).
 func (engine *Engine) Start() error {
 	return engine.run(context.Background(), true)
 }
@@ -151,6 +203,21 @@ func (engine *Engine) Processes() []process.ProcessInfo {
 	return engine.ProcessManager.Snapshot()
 }
 
+// Logs returns up to n of the most recent output lines of the named process,
+// oldest first, across stdout and stderr (n <= 0 returns everything retained).
+// Requires Config.LogBuffer; returns nil when buffering is off or the name is
+// unknown. Safe to call from any goroutine.
+func (engine *Engine) Logs(name string, n int) []process.LogLine {
+	return engine.ProcessManager.Logs(name, n)
+}
+
+// FollowLogs streams each new output line of the named process, across
+// restarts, until ctx is cancelled. Lines are dropped rather than blocking the
+// process if the consumer falls behind. Requires Config.LogBuffer.
+func (engine *Engine) FollowLogs(ctx context.Context, name string) (<-chan process.LogLine, error) {
+	return engine.ProcessManager.FollowLogs(ctx, name)
+}
+
 func (engine *Engine) run(parent context.Context, trapOSSignals bool) error {
 	slog.Info("refresh starting")
 
@@ -164,6 +231,13 @@ func (engine *Engine) run(parent context.Context, trapOSSignals bool) error {
 	ctx, cancel := context.WithCancel(parent)
 	engine.ctx = ctx
 	engine.cancel = cancel
+	// From here the supervisor owns the process manager: runtime operations
+	// queue on controlCh until the loop below serves them.
+	engine.supervising.Store(true)
+	defer func() {
+		engine.stopped.Store(true)
+		engine.supervising.Store(false)
+	}()
 
 	// Trap signals before the initial pass: it starts processes and runs blocking
 	// steps synchronously, so an interrupt mid-pass must cancel ctx to tear them down.
@@ -172,14 +246,15 @@ func (engine *Engine) run(parent context.Context, trapOSSignals bool) error {
 	}
 
 	// Initial pass over all configured processes.
-	if err := engine.ProcessManager.Start(ctx); err != nil {
-		engine.ProcessManager.Shutdown()
+	if err := engine.runReload(ctx, reloadBatch{trigger: TriggerStartup}, true); err != nil {
+		engine.shutdown(ctx)
 		// A cancelled context means an interrupt arrived mid-startup: that's a
 		// clean shutdown, not a startup failure, so don't surface it as an error.
 		if ctx.Err() != nil {
 			slog.Info("refresh stopped")
 			return nil
 		}
+		engine.summarizeDiagnostics()
 		cancel()
 		return fmt.Errorf("starting processes: %w", err)
 	}
@@ -188,9 +263,24 @@ func (engine *Engine) run(parent context.Context, trapOSSignals bool) error {
 	// Reload uses, so file-driven and programmatic reloads share one path.
 	if err := engine.startWatcher(ctx, engine.reloadCh); err != nil {
 		engine.Stop()
-		engine.ProcessManager.Shutdown()
+		engine.shutdown(ctx)
 		return err
 	}
+	// An engine built from a config file follows edits to it. Failing to watch
+	// it only loses that convenience, so it is not fatal.
+	if engine.configPath != "" {
+		if err := engine.watchConfig(ctx, *engine.configFiles.Load()); err != nil {
+			slog.Warn("not watching config file for changes", "path", engine.configPath, "err", err)
+		}
+	}
+
+	if engine.Config.Proxy.Listen != "" {
+		if err := engine.startProxy(ctx); err != nil {
+			engine.Stop()
+			engine.shutdown(ctx)
+			return err
+		}
+	}
 
 	// Optional pause/resume via the suspend key (Ctrl+Z). Only wired when this
 	// engine owns OS signals; an embedding caller (Run) drives Pause/Resume
@@ -201,38 +291,86 @@ func (engine *Engine) run(parent context.Context, trapOSSignals bool) error {
 	}
 
 	// Supervisor loop: the only goroutine that drives process lifecycle, so the
-	// process manager needs no locking around its handles. pending lives here;
+	// process manager needs no locking around its handles. deferred lives here;
 	// the pause flag is the engine's atomic, set by Pause/Resume.
-	pending := false // a reload arrived while paused
+	var deferred *reloadBatch // a reload arrived while paused
 
 	for {
 		select {
 		case <-ctx.Done():
-			engine.ProcessManager.Shutdown()
+			engine.shutdown(ctx)
 			slog.Info("refresh stopped")
 			return nil
 		case <-engine.wakeCh:
 			// A resume occurred; apply any change deferred while paused.
-			if !engine.paused.Load() && pending {
-				pending = false
+			if !engine.paused.Load() && deferred != nil {
+				batch := *deferred
+				deferred = nil
+				batch.trigger = TriggerResume
 				slog.Info("applying change made while paused, reloading")
-				if err := engine.ProcessManager.Reload(ctx); err != nil {
-					slog.Error("reload failed", "err", err)
-				}
+				engine.reload(ctx, batch)
+			}
+		case req := <-engine.controlCh:
+			req.done <- req.fn(ctx)
+		case <-engine.configCh:
+			engine.reloadConfig(ctx)
+		case ev := <-engine.exitCh:
+			if ev.Info.State == process.StateFailed {
+				engine.runHook(ctx, engine.Config.Hooks.OnPrimaryCrash, HookContext{
+					Hook:     HookOnPrimaryCrash,
+					Cycle:    engine.cycle - 1,
+					Process:  ev.Info.Name,
+					ExitCode: ev.Info.ExitCode,
+					Err:      ev.Err,
+				}, false)
+			}
+			if err := engine.primaryExitError(ev); err != nil {
+				engine.shutdown(ctx)
+				cancel()
+				slog.Info("refresh stopped")
+				return err
 			}
 		case <-engine.reloadCh:
+			batch := engine.takePending()
+			if batch.trigger == TriggerFileChange {
+				engine.runHook(ctx, engine.Config.Hooks.OnChange, HookContext{
+					Hook:    HookOnChange,
+					Cycle:   engine.cycle,
+					Trigger: batch.trigger,
+					Paths:   batch.paths,
+				}, false)
+			}
 			if engine.paused.Load() {
-				pending = true
+				if deferred == nil {
+					deferred = &reloadBatch{}
+				}
+				deferred.merge(batch.trigger, batch.paths)
+				engine.emitReload(ReloadEvent{Kind: ReloadDeferred, Cycle: engine.cycle, Trigger: batch.trigger, Paths: deferred.paths})
 				continue
 			}
 			slog.Info("change detected, reloading")
-			if err := engine.ProcessManager.Reload(ctx); err != nil {
-				slog.Error("reload failed", "err", err)
-			}
+			engine.reload(ctx, batch)
 		}
 	}
 }
 
+// reload runs a reload cycle from the supervisor loop, logging a failure and
+// its diagnostics summary; the loop carries on watching either way.
+func (engine *Engine) reload(ctx context.Context, batch reloadBatch) {
+	if err := engine.runReload(ctx, batch, false); err != nil && ctx.Err() == nil {
+		slog.Error("reload failed", "err", err)
+		engine.summarizeDiagnostics()
+	}
+}
+
+// shutdown stops every process and then runs the on_shutdown hook. The hook
+// is not bound to ctx, which is usually already cancelled by then, only to its
+// timeout.
+func (engine *Engine) shutdown(ctx context.Context) {
+	engine.ProcessManager.Shutdown()
+	engine.runHook(context.WithoutCancel(ctx), engine.Config.Hooks.OnShutdown, HookContext{Hook: HookOnShutdown, Cycle: engine.cycle}, true)
+}
+
 // Stop requests a graceful shutdown. The supervisor loop performs the actual
 // process teardown when the context is cancelled.
 func (engine *Engine) Stop() {
@@ -259,39 +397,53 @@ func NewEngineFromConfig(options Config) (*Engine, error) {
 		return nil, err
 	}
 	engine.ProcessManager = process.NewProcessManager()
-	engine.generateProcess()
+	if err := engine.generateProcess(); err != nil {
+		return nil, err
+	}
 	_ = engine.ProcessManager.SetRootDirectory(engine.Config.RootPath)
 	return engine, nil
 }
 
 func NewEngineFromTOML(confPath string) (*Engine, error) {
-	engine := &Engine{}
-	engine.initControl()
-	if _, err := engine.readConfigFile(confPath); err != nil {
-		return nil, err
-	}
-	engine.initLogger()
-	if err := engine.verifyConfig(); err != nil {
-		return nil, err
-	}
-	engine.ProcessManager = process.NewProcessManager()
-	engine.generateProcess()
-	_ = engine.ProcessManager.SetRootDirectory(engine.Config.RootPath)
-	return engine, nil
+	return newEngineFromFile(confPath, decodeTOML, Overrides{})
 }
 
 func NewEngineFromYAML(confPath string) (*Engine, error) {
-	engine := &Engine{}
+	return newEngineFromFile(confPath, decodeYAML, Overrides{})
+}
+
+func NewEngineFromJSON(confPath string) (*Engine, error) {
+	return newEngineFromFile(confPath, decodeJSON, Overrides{})
+}
+
+// NewEngineFromFile builds an engine from a config file, TOML, YAML or JSON by
+// its extension, with overrides applied on top of the file, its profile and
+// the environment, now and whenever the file is reloaded.
+func NewEngineFromFile(confPath string, overrides Overrides) (*Engine, error) {
+	return newEngineFromFile(confPath, decoderFor(confPath), overrides)
+}
+
+func newEngineFromFile(confPath string, decode decoder, overrides Overrides) (*Engine, error) {
+	engine := &Engine{overrides: overrides}
 	engine.initControl()
-	if _, err := engine.readConfigYaml(confPath); err != nil {
+	if err := engine.readConfig(confPath, decode); err != nil {
 		return nil, err
 	}
+	if engine.Config.Strict {
+		if err := validateStrict(confPath, overrides); err != nil {
+			return nil, err
+		}
+	}
+	engine.configPath, _ = filepath.Abs(confPath)
+	engine.configFiles.Store(&engine.loaded)
 	engine.initLogger()
 	if err := engine.verifyConfig(); err != nil {
 		return nil, err
 	}
 	engine.ProcessManager = process.NewProcessManager()
-	engine.generateProcess()
+	if err := engine.generateProcess(); err != nil {
+		return nil, err
+	}
 	_ = engine.ProcessManager.SetRootDirectory(engine.Config.RootPath)
 	return engine, nil
 }
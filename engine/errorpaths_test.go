@@ -55,3 +55,25 @@ func TestStringToConfigInvalid(t *testing.T) {
 		t.Error("expected error for invalid YAML string")
 	}
 }
+
+// TestNewEngineFromConfigRejectsInvalidExecute checks an execute the process
+// manager would refuse fails the engine rather than silently going missing.
+func TestNewEngineFromConfigRejectsInvalidExecute(t *testing.T) {
+	tests := map[string]Execute{
+		"restart strategy": {Name: "app", Cmd: "./app", Type: Primary, RestartStrategy: "blue-green"},
+		"cmd and args":     {Name: "app", Cmd: "./app", Args: []string{"./app"}, Type: Primary},
+		"port handed off":  {Name: "app", Cmd: "./app", Type: Primary, Listen: []string{":8080"}, Ports: []int{8080}},
+	}
+	for name, bad := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewEngineFromConfig(Config{
+				RootPath:   t.TempDir(),
+				LogLevel:   "mute",
+				ExecStruct: []Execute{{Name: "build", Cmd: "go build", Type: Blocking}, bad},
+			})
+			if err == nil {
+				t.Fatal("accepted an invalid execute")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// ExitPolicy decides whether the engine stops when the primary process exits on
+// its own, rather than being restarted or stopped by refresh.
+type ExitPolicy string
+
+const (
+	// ExitNever keeps watching after the primary exits; the next change starts
+	// it again. The default.
+	ExitNever ExitPolicy = "never"
+	// ExitOnPrimaryExit stops the engine whenever the primary exits, with
+	// whatever status — for iterating on a one-shot CLI tool.
+	ExitOnPrimaryExit ExitPolicy = "primary-exit"
+	// ExitOnPrimaryFailure stops the engine when the primary exits with a
+	// non-zero status or dies from a signal; a clean exit keeps watching.
+	ExitOnPrimaryFailure ExitPolicy = "primary-failure"
+)
+
+// exitPolicies lists the valid ExitPolicy values; empty means ExitNever.
+var exitPolicies = []ExitPolicy{ExitNever, ExitOnPrimaryExit, ExitOnPrimaryFailure}
+
+func (p ExitPolicy) valid() bool {
+	return p == "" || slices.Contains(exitPolicies, p)
+}
+
+// stops reports whether the policy ends the engine for a primary that exited
+// on its own in the given state.
+func (p ExitPolicy) stops(state process.ProcessState) bool {
+	switch p {
+	case ExitOnPrimaryExit:
+		return state == process.StateExited || state == process.StateFailed
+	case ExitOnPrimaryFailure:
+		return state == process.StateFailed
+	}
+	return false
+}
+
+// errPrimaryExited is the step error of a primary that exited cleanly.
+var errPrimaryExited = errors.New("primary process exited")
+
+// notePrimaryExit forwards a primary exiting on its own to the supervisor,
+// which applies the exit policy. Called from the process's wait goroutine.
+func (engine *Engine) notePrimaryExit(ev process.ProcessEvent) {
+	if ev.Info.Type != process.Primary {
+		return
+	}
+	if ev.Info.State != process.StateExited && ev.Info.State != process.StateFailed {
+		return
+	}
+	select {
+	case engine.exitCh <- ev:
+	default:
+	}
+}
+
+// primaryExitError applies the exit policy to a primary exit reported by
+// notePrimaryExit, returning the error the engine stops with, or nil to keep
+// running. An exit that a later cycle has since superseded (the primary is
+// running again) is ignored. Called only from the supervisor goroutine.
+func (engine *Engine) primaryExitError(ev process.ProcessEvent) *ExitError {
+	if !engine.Config.ExitOn.stops(ev.Info.State) {
+		return nil
+	}
+	for _, info := range engine.ProcessManager.Snapshot() {
+		if info.Name == ev.Info.Name && info.State == process.StateRunning {
+			return nil
+		}
+	}
+	code := ev.Info.ExitCode
+	if ev.Info.State == process.StateFailed && code <= 0 {
+		code = 1
+	}
+	err := ev.Err
+	if err == nil {
+		err = errPrimaryExited
+	}
+	slog.Info("primary exited, stopping", "name", ev.Info.Name, "exit_code", code, "exit_on", engine.Config.ExitOn)
+	return &ExitError{Code: code, Err: &process.StepError{Name: ev.Info.Name, ExitCode: ev.Info.ExitCode, Err: err}}
+}
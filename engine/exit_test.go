@@ -0,0 +1,74 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func runWithExitPolicy(t *testing.T, policy ExitPolicy, cmd string) error {
+	t.Helper()
+	eng, err := NewEngineFromConfig(Config{
+		RootPath:   t.TempDir(),
+		LogLevel:   "mute",
+		Debounce:   100,
+		ExitOn:     policy,
+		ExecStruct: []Execute{{Name: "app", Cmd: cmd, Type: Primary}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return eng.Run(ctx)
+}
+
+// TestExitOnPolicy checks which primary exits stop the engine, and that the
+// returned ExitError carries the primary's status.
+func TestExitOnPolicy(t *testing.T) {
+	tests := []struct {
+		policy ExitPolicy
+		cmd    string
+		stops  bool
+		code   int
+	}{
+		{ExitOnPrimaryFailure, "exit 4", true, 4},
+		{ExitOnPrimaryFailure, "true", false, 0},
+		{ExitOnPrimaryExit, "true", true, 0},
+		{ExitOnPrimaryExit, "exit 2", true, 2},
+		{ExitNever, "exit 4", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+"/"+tt.cmd, func(t *testing.T) {
+			start := time.Now()
+			err := runWithExitPolicy(t, tt.policy, tt.cmd)
+			if !tt.stops {
+				if err != nil || time.Since(start) < 900*time.Millisecond {
+					t.Fatalf("Run returned %v after %s, want it to keep running until cancelled", err, time.Since(start))
+				}
+				return
+			}
+			var exit *ExitError
+			if !errors.As(err, &exit) {
+				t.Fatalf("Run = %v, want an ExitError", err)
+			}
+			if exit.Code != tt.code {
+				t.Errorf("exit code = %d, want %d", exit.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestInvalidExitPolicy(t *testing.T) {
+	_, err := NewEngineFromConfig(Config{
+		RootPath:   ".",
+		ExitOn:     "sometimes",
+		ExecStruct: []Execute{{Cmd: "true", Type: Primary}},
+	})
+	if err == nil {
+		t.Fatal("expected an invalid exit_on to be rejected")
+	}
+}
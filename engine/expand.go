@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// expandConfig expands ${VAR} and ${VAR:-default} references in every string
+// of a config loaded from a file or string. Besides environment variables, a
+// few built-ins are available:
+//
+//	ROOT        the absolute root path (root_path itself cannot use it)
+//	CONFIG_DIR  the directory holding the config file (the working directory
+//	            for configs loaded from a string)
+//	GOOS        the target OS: $GOOS if set, else the OS refresh runs on
+//	GOARCH      the target architecture, likewise
+//
+// Only the braced form is expanded, so $HOME or $1 in a command is left for
+// the shell; write $${ for a literal ${.
+func (engine *Engine) expandConfig(configDir string) {
+	vars := map[string]string{
+		"CONFIG_DIR": configDir,
+		"GOOS":       cmp.Or(os.Getenv("GOOS"), runtime.GOOS),
+		"GOARCH":     cmp.Or(os.Getenv("GOARCH"), runtime.GOARCH),
+	}
+	lookup := func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+		return os.LookupEnv(name)
+	}
+	engine.Config.RootPath = expandVars(engine.Config.RootPath, lookup)
+	if engine.overrides.ConfigRelativeRoot && !filepath.IsAbs(engine.Config.RootPath) && fromConfig(engine.Config.Source("root_path")) {
+		engine.Config.RootPath = filepath.Join(configDir, engine.Config.RootPath)
+	}
+	root := engine.Config.RootPath
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	vars["ROOT"] = root
+	expandStrings(reflect.ValueOf(&engine.Config).Elem(), func(s string) string {
+		return expandVars(s, lookup)
+	})
+}
+
+// fromConfig reports whether a setting's Source is the config itself (a file
+// or profile) or its default, rather than the environment or an override.
+func fromConfig(source string) bool {
+	return source == "default" || strings.HasPrefix(source, "file ") || strings.HasPrefix(source, "profile ")
+}
+
+// configDir is the CONFIG_DIR of a config file.
+func configDir(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Dir(path)
+	}
+	return filepath.Dir(abs)
+}
+
+// workingDir is the CONFIG_DIR of a config loaded from a string.
+func workingDir() string {
+	wd, _ := os.Getwd()
+	return wd
+}
+
+// expandStrings applies expand to every settable string reachable from v
+// through exported struct fields and slices.
+func expandStrings(v reflect.Value, expand func(string) string) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(expand(v.String()))
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				expandStrings(v.Field(i), expand)
+			}
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			expandStrings(v.Index(i), expand)
+		}
+	}
+}
+
+// expandVars replaces ${NAME} with its value (empty when unset) and
+// ${NAME:-default} with its value, or default when it is unset or empty.
+// Defaults may themselves contain references. $${ escapes a literal ${.
+func expandVars(s string, lookup func(string) (string, bool)) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], "$${") {
+			b.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(s[i:], "${") {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := closingBrace(s, i+2)
+		if end < 0 {
+			// Unterminated: keep the rest as written.
+			b.WriteString(s[i:])
+			break
+		}
+		ref := s[i+2 : end]
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		value, ok := lookup(name)
+		if hasDefault && (!ok || value == "") {
+			value = expandVars(def, lookup)
+		}
+		b.WriteString(value)
+		i = end + 1
+	}
+	return b.String()
+}
+
+// closingBrace returns the index of the } closing a reference whose body
+// starts at start, allowing nested ${...} in defaults, or -1.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	env := map[string]string{"PORT": "8080", "EMPTY": "", "HOST": "localhost"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	tests := []struct{ in, want string }{
+		{"./app -port ${PORT}", "./app -port 8080"},
+		{"${MISSING}", ""},
+		{"${MISSING:-3000}", "3000"},
+		{"${EMPTY:-fallback}", "fallback"},
+		{"${PORT:-3000}", "8080"},
+		{"${MISSING:-${HOST}:${PORT}}", "localhost:8080"},
+		{"echo $HOME $1", "echo $HOME $1"},
+		{"echo $${PORT}", "echo ${PORT}"},
+		{"${PORT", "${PORT"},
+	}
+	for _, tt := range tests {
+		if got := expandVars(tt.in, lookup); got != tt.want {
+			t.Errorf("expandVars(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// TestConfigFileExpandsVariables checks expansion reaches every string field
+// of a config file, with environment variables, defaults and built-ins.
+func TestConfigFileExpandsVariables(t *testing.T) {
+	t.Setenv("REFRESH_TEST_PORT", "9000")
+	t.Setenv("GOOS", "")
+	dir := t.TempDir()
+	root := filepath.Join(dir, "svc")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "refresh.toml")
+	config := `[config]
+root_path = "${CONFIG_DIR}/svc"
+log_level = "${REFRESH_TEST_LEVEL:-mute}"
+
+[config.ignore]
+dir = ["${ROOT}/tmp"]
+
+[[config.executes]]
+name = "app-${GOOS}"
+cmd = "./app -port ${REFRESH_TEST_PORT}"
+dir = "${ROOT}"
+type = "primary"
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng, err := NewEngineFromTOML(path)
+	if err != nil {
+		t.Fatalf("NewEngineFromTOML: %v", err)
+	}
+	cfg := eng.Config
+	if cfg.RootPath != root {
+		t.Errorf("RootPath = %q, want %q", cfg.RootPath, root)
+	}
+	if cfg.LogLevel != "mute" {
+		t.Errorf("LogLevel = %q, want the default mute", cfg.LogLevel)
+	}
+	if want := filepath.Join(root, "tmp"); len(cfg.Ignore.Dir) != 1 || cfg.Ignore.Dir[0] != want {
+		t.Errorf("Ignore.Dir = %v, want [%s]", cfg.Ignore.Dir, want)
+	}
+	exe := cfg.ExecStruct[0]
+	if exe.Name != "app-"+runtime.GOOS || exe.Cmd != "./app -port 9000" || exe.ChangeDir != root {
+		t.Errorf("execute = %+v, want name app-%s, port 9000 and dir %s", exe, runtime.GOOS, root)
+	}
+}
+
+func TestStringConfigExpandsVariables(t *testing.T) {
+	t.Setenv("REFRESH_TEST_CMD", "./server")
+	e := &Engine{}
+	if err := e.StringtoConfigYAML("config:\n  root_path: .\n  executes:\n    - cmd: ${REFRESH_TEST_CMD} -v\n      type: primary\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Config.ExecStruct[0].Cmd; got != "./server -v" {
+		t.Errorf("Cmd = %q, want ./server -v", got)
+	}
+	e = &Engine{}
+	if err := e.StringtoConfigJSON(`{"config": {"root_path": "${REFRESH_TEST_MISSING:-.}"}}`); err != nil {
+		t.Fatal(err)
+	}
+	if e.Config.RootPath != "." {
+		t.Errorf("RootPath = %q, want the default .", e.Config.RootPath)
+	}
+}
@@ -7,8 +7,9 @@ import "github.com/atterpac/refresh/process"
 // documented `refresh.Execute{...}` / `refresh.KILL_EXEC` usage without a second
 // import.
 type (
-	Execute     = process.Execute
-	ExecuteType = process.ExecuteType
+	Execute         = process.Execute
+	ExecuteType     = process.ExecuteType
+	RestartStrategy = process.RestartStrategy
 
 	// Observability types for SDK consumers (e.g. a TUI) tapping per-process
 	// output and lifecycle. See the process package for documentation.
@@ -17,6 +18,18 @@ type (
 	ProcessState = process.ProcessState
 	OutputFunc   = process.OutputFunc
 	EventFunc    = process.EventFunc
+	LogLine      = process.LogLine
+	Metrics      = process.Metrics
+	MetricsFunc  = process.MetricsFunc
+
+	// Resource limits of a process, and why a limit ended one.
+	Limits        = process.Limits
+	FailureReason = process.FailureReason
+	LimitError    = process.LimitError
+
+	// Structured build diagnostics parsed from failed steps.
+	Diagnostic       = process.Diagnostic
+	DiagnosticParser = process.DiagnosticParser
 )
 
 var (
@@ -24,12 +37,21 @@ var (
 	Once       = process.Once
 	Blocking   = process.Blocking
 	Primary    = process.Primary
+	Periodic   = process.Periodic
+
+	StopFirst  = process.StopFirst
+	StartFirst = process.StartFirst
 
 	StatePending = process.StatePending
 	StateRunning = process.StateRunning
 	StateExited  = process.StateExited
 	StateFailed  = process.StateFailed
 	StateKilled  = process.StateKilled
+	StateRemoved = process.StateRemoved
+	StateSkipped = process.StateSkipped
+
+	ReasonMemoryLimit = process.ReasonMemoryLimit
+	ReasonCPULimit    = process.ReasonCPULimit
 
 	// KILL_STALE is a marker execute (struct form) indicating where a stale
 	// primary should be terminated. The supervisor now restarts the primary
@@ -41,4 +63,7 @@ var (
 	// REFRESH_EXEC marks the command that follows it as the primary process.
 	KILL_EXEC    = process.KILL_EXEC
 	REFRESH_EXEC = process.REFRESH_EXEC
+
+	// NewRegexParser builds a custom DiagnosticParser from a line pattern.
+	NewRegexParser = process.NewRegexParser
 )
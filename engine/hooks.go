@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// Hook is a command, or for SDK users a Go function, run at a point of the
+// engine's lifecycle. In a config file it is written as the command string.
+// Func takes precedence over Cmd when both are set.
+type Hook struct {
+	// Cmd runs through the config's shell in the root directory, with the
+	// HookContext in its environment (see HookContext.Env).
+	Cmd string
+	// Func is called with the HookContext instead of running Cmd.
+	Func func(HookContext) error
+}
+
+// UnmarshalText sets the hook's command, so a config file gives a hook as a
+// plain string.
+func (h *Hook) UnmarshalText(text []byte) error {
+	h.Cmd = string(text)
+	return nil
+}
+
+// MarshalText writes the hook as its command.
+func (h Hook) MarshalText() ([]byte, error) {
+	return []byte(h.Cmd), nil
+}
+
+func (h Hook) set() bool {
+	return h.Func != nil || h.Cmd != ""
+}
+
+// Hooks are run around reloads, primary crashes and shutdown. Hooks the engine
+// waits for (before_reload and on_shutdown) hold up the engine itself: until
+// one returns, or its command times out after 30 seconds, no reload, runtime
+// process change or shutdown proceeds, so keep them short. The others run in
+// the background. A failing hook is logged and never fails the reload or the
+// engine.
+type Hooks struct {
+	// OnChange runs when a watched file change requests a reload, also while
+	// paused.
+	OnChange Hook `toml:"on_change"            yaml:"on_change"            json:"on_change"`
+	// BeforeReload runs, and is waited for, before each reload cycle's steps.
+	BeforeReload Hook `toml:"before_reload"        yaml:"before_reload"        json:"before_reload"`
+	// AfterReloadSuccess runs when a reload cycle completes.
+	AfterReloadSuccess Hook `toml:"after_reload_success" yaml:"after_reload_success" json:"after_reload_success"`
+	// AfterReloadFailure runs when a step fails a reload cycle.
+	AfterReloadFailure Hook `toml:"after_reload_failure" yaml:"after_reload_failure" json:"after_reload_failure"`
+	// OnPrimaryCrash runs when the primary exits on its own with a non-zero
+	// status or from a signal.
+	OnPrimaryCrash Hook `toml:"on_primary_crash"     yaml:"on_primary_crash"     json:"on_primary_crash"`
+	// OnShutdown runs, and is waited for, once the engine has stopped its
+	// processes.
+	OnShutdown Hook `toml:"on_shutdown"          yaml:"on_shutdown"          json:"on_shutdown"`
+}
+
+// all returns the hooks in declaration order.
+func (h *Hooks) all() []*Hook {
+	return []*Hook{&h.OnChange, &h.BeforeReload, &h.AfterReloadSuccess, &h.AfterReloadFailure, &h.OnPrimaryCrash, &h.OnShutdown}
+}
+
+// Names of the hooks, as given in HookContext.Hook and $REFRESH_HOOK.
+const (
+	HookOnChange           = "on_change"
+	HookBeforeReload       = "before_reload"
+	HookAfterReloadSuccess = "after_reload_success"
+	HookAfterReloadFailure = "after_reload_failure"
+	HookOnPrimaryCrash     = "on_primary_crash"
+	HookOnShutdown         = "on_shutdown"
+)
+
+// HookContext describes what a hook runs for. Fields that do not apply to the
+// hook are left zero.
+type HookContext struct {
+	// Hook is the hook's name, such as "before_reload".
+	Hook string
+	// Cycle is the reload cycle the hook belongs to.
+	Cycle int
+	// Trigger is what caused the reload.
+	Trigger ReloadTrigger
+	// Paths are the changed files, relative to the root.
+	Paths []string
+	// Process names the step that failed the reload, or the crashed primary.
+	Process string
+	// ExitCode is the failing process's exit code, or -1 when it did not exit
+	// on its own.
+	ExitCode int
+	// Err is the failure.
+	Err error
+}
+
+// Env returns the context as the environment variables a hook command sees:
+// REFRESH_HOOK, REFRESH_CYCLE, REFRESH_TRIGGER, REFRESH_CHANGED_FILES (one
+// path per line), REFRESH_PROCESS, REFRESH_EXIT_CODE and REFRESH_ERROR.
+func (hc HookContext) Env() []string {
+	env := []string{
+		"REFRESH_HOOK=" + hc.Hook,
+		"REFRESH_CYCLE=" + strconv.Itoa(hc.Cycle),
+		"REFRESH_TRIGGER=" + string(hc.Trigger),
+		"REFRESH_CHANGED_FILES=" + strings.Join(hc.Paths, "\n"),
+		"REFRESH_PROCESS=" + hc.Process,
+		"REFRESH_EXIT_CODE=",
+		"REFRESH_ERROR=",
+	}
+	if hc.Process != "" {
+		env[5] += strconv.Itoa(hc.ExitCode)
+	}
+	if hc.Err != nil {
+		env[6] += hc.Err.Error()
+	}
+	return env
+}
+
+// hookTimeout bounds a hook command, so a hung hook cannot stall a reload or
+// shutdown for long.
+const hookTimeout = 30 * time.Second
+
+// runHook runs hook for hc, waiting for it when wait is set and otherwise in
+// the background. A hook command is killed when ctx, the engine's, is done.
+// Failures are logged. Called only from the supervisor goroutine, which owns
+// the config a config reload changes, so a hook in the background takes the
+// shell it runs with along.
+func (engine *Engine) runHook(ctx context.Context, hook Hook, hc HookContext, wait bool) {
+	if !hook.set() {
+		return
+	}
+	shell := engine.Config.Shell
+	if wait {
+		engine.execHook(ctx, hook, hc, shell)
+		return
+	}
+	go engine.execHook(ctx, hook, hc, shell)
+}
+
+func (engine *Engine) execHook(ctx context.Context, hook Hook, hc HookContext, shell string) {
+	var err error
+	if hook.Func != nil {
+		err = hook.Func(hc)
+	} else {
+		err = engine.hookCommand(ctx, hook.Cmd, shell, hc)
+	}
+	if err != nil {
+		slog.Warn("hook failed", "hook", hc.Hook, "err", err)
+	}
+}
+
+// hookCommand runs a hook's command in the root directory with shell, the
+// config's. Its output goes where process output goes, under the name
+// "hook:<name>". The command and everything it starts are killed after
+// hookTimeout or when ctx is done.
+func (engine *Engine) hookCommand(ctx context.Context, command, shell string, hc HookContext) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	cmd := process.CommandContext(ctx, process.Execute{Cmd: command, Shell: shell})
+	cmd.Dir = engine.ProcessManager.RootDir
+	cmd.Env = append(os.Environ(), hc.Env()...)
+	info := process.ProcessInfo{Name: "hook:" + hc.Hook, Exec: command, State: process.StateRunning, StartedAt: time.Now()}
+	cmd.Stdout = engine.hookOutput(info, "stdout", os.Stdout)
+	cmd.Stderr = engine.hookOutput(info, "stderr", os.Stderr)
+	slog.Debug("running hook", "hook", hc.Hook, "cmd", command)
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("%s: timed out after %s", command, hookTimeout)
+		}
+		return fmt.Errorf("%s: %w", command, err)
+	}
+	return nil
+}
+
+func (engine *Engine) hookOutput(info process.ProcessInfo, stream string, fallback io.Writer) io.Writer {
+	if engine.Config.Output != nil {
+		if w := engine.Config.Output(info, stream); w != nil {
+			return w
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,147 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHooks checks the reload hooks receive their context around a successful
+// and a failing reload, and that a hook command sees it in its environment.
+func TestHooks(t *testing.T) {
+	root := t.TempDir()
+	calls := make(chan HookContext, 8)
+	record := Hook{Func: func(hc HookContext) error {
+		calls <- hc
+		return nil
+	}}
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: root,
+		LogLevel: "mute",
+		Debounce: 100,
+		ExecStruct: []Execute{
+			{Name: "check", Cmd: "test ! -f broken", Type: Blocking},
+			{Name: "app", Cmd: "sleep 30", Type: Primary},
+		},
+		Hooks: Hooks{
+			BeforeReload:       record,
+			AfterReloadSuccess: record,
+			AfterReloadFailure: record,
+			OnShutdown:         Hook{Cmd: `echo "$REFRESH_HOOK $REFRESH_CYCLE" > shutdown.txt`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+
+	next := func() HookContext {
+		t.Helper()
+		select {
+		case hc := <-calls:
+			return hc
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a hook")
+			return HookContext{}
+		}
+	}
+
+	// The startup pass runs no reload hooks.
+	eng.Reload()
+	if hc := next(); hc.Hook != HookBeforeReload || hc.Cycle != 1 || hc.Trigger != TriggerReload {
+		t.Errorf("first hook = %+v, want before_reload of cycle 1", hc)
+	}
+	if hc := next(); hc.Hook != HookAfterReloadSuccess || hc.Cycle != 1 {
+		t.Errorf("second hook = %+v, want after_reload_success of cycle 1", hc)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng.Reload()
+	next() // before_reload
+	hc := next()
+	if hc.Hook != HookAfterReloadFailure || hc.Process != "check" || hc.ExitCode != 1 || hc.Err == nil {
+		t.Errorf("failure hook = %+v, want after_reload_failure for check with exit code 1", hc)
+	}
+	if env := strings.Join(hc.Env(), "\n"); !strings.Contains(env, "REFRESH_PROCESS=check\n") || !strings.Contains(env, "REFRESH_EXIT_CODE=1\n") {
+		t.Errorf("Env() = %q, want the failing process and its exit code", env)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "shutdown.txt"))
+	if err != nil || strings.TrimSpace(string(data)) != "on_shutdown 3" {
+		t.Errorf("on_shutdown wrote %q (%v), want \"on_shutdown 3\"", data, err)
+	}
+}
+
+func TestHooksFromConfigFile(t *testing.T) {
+	path := writeConfig(t, "refresh.yaml", `
+config:
+  root_path: .
+  hooks:
+    before_reload: rm -rf .cache
+    on_primary_crash: notify-send crashed
+  executes:
+    - cmd: ./app
+      type: primary
+`)
+	eng, err := NewEngineFromYAML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks := eng.Config.Hooks
+	if hooks.BeforeReload.Cmd != "rm -rf .cache" || hooks.OnPrimaryCrash.Cmd != "notify-send crashed" || hooks.OnShutdown.set() {
+		t.Errorf("hooks = %+v", hooks)
+	}
+}
+
+// TestHookKilledWithEngine checks a hook command still running when the engine
+// stops is killed together with what it started, not just its shell.
+func TestHookKilledWithEngine(t *testing.T) {
+	root := t.TempDir()
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: root,
+		LogLevel: "mute",
+		ExecStruct: []Execute{
+			{Name: "app", Cmd: "sleep 30", Type: Primary},
+		},
+		Hooks: Hooks{
+			AfterReloadSuccess: Hook{Cmd: `(i=0; while :; do i=$((i+1)); echo $i > tick; sleep 0.05; done) & wait`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+
+	tick := filepath.Join(root, "tick")
+	eng.Reload()
+	if !waitFor(func() bool { _, err := os.Stat(tick); return err == nil }) {
+		t.Fatal("hook never started")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	before, _ := os.ReadFile(tick)
+	time.Sleep(300 * time.Millisecond)
+	if after, _ := os.ReadFile(tick); string(after) != string(before) {
+		t.Error("hook's background loop still running after the engine stopped")
+	}
+}
@@ -5,13 +5,15 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+
+	"github.com/atterpac/refresh/process"
 )
 
 type Ignore struct {
-	Dir          []string `toml:"dir"               yaml:"dir"`
-	File         []string `toml:"file"              yaml:"file"`
-	WatchedExten []string `toml:"watched_extension" yaml:"watched_extension"`
-	IgnoreGit    bool     `toml:"git"               yaml:"git"`
+	Dir          []string `toml:"dir"               yaml:"dir"               json:"dir"`
+	File         []string `toml:"file"              yaml:"file"              json:"file"`
+	WatchedExten []string `toml:"watched_extension" yaml:"watched_extension" json:"watched_extension"`
+	IgnoreGit    bool     `toml:"git"               yaml:"git"               json:"git"`
 
 	// gitPatterns holds globs read from the root .gitignore when IgnoreGit is
 	// set. Populated by the engine at startup; not user-configured.
@@ -21,8 +23,9 @@ type Ignore struct {
 // shouldIgnore reports whether a change to path should be skipped. A path is
 // considered only if it matches a watched extension; it is then ignored if it
 // sits in an ignored directory or matches an ignore-file or .gitignore pattern.
+// refresh's own process.CacheDir is always ignored.
 func (i *Ignore) shouldIgnore(path string) bool {
-	if !i.isWatchedExtension(path) {
+	if !i.isWatchedExtension(path) || isIgnoreDir(path, []string{process.CacheDir}) {
 		return true
 	}
 	return isIgnoreDir(path, i.Dir) ||
@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// keyPos is where a key sits in a config file. Path holds the key's segments
+// from the document root, with list elements as their decimal index, e.g.
+// ["config", "executes", "1", "type"].
+type keyPos struct {
+	path      []string
+	line, col int
+}
+
+// keyIndex maps the keys of one config file to their positions. It is built by
+// a light line scanner per format rather than a full parser: the decoders used
+// to load configs do not report positions, and validation only needs to point
+// at the right line.
+type keyIndex []keyPos
+
+// find returns the position of path, falling back to its nearest located
+// ancestor (for instance a key inside an inline table). Zero means unknown.
+func (idx keyIndex) find(path []string) (line, col int) {
+	best := -1
+	for i, kp := range idx {
+		if len(kp.path) > len(path) || !slices.Equal(kp.path, path[:len(kp.path)]) {
+			continue
+		}
+		if best < 0 || len(kp.path) > len(idx[best].path) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return 0, 0
+	}
+	return idx[best].line, idx[best].col
+}
+
+// matching returns every located key whose path, with list indices removed,
+// is key — all the places a key reported without indices can appear.
+func (idx keyIndex) matching(key []string) []keyPos {
+	var out []keyPos
+	for _, kp := range idx {
+		if slices.Equal(withoutIndices(kp.path), key) {
+			out = append(out, kp)
+		}
+	}
+	return out
+}
+
+// at returns the key named name located on line, or the first key on it when
+// name is empty.
+func (idx keyIndex) at(line int, name string) (keyPos, bool) {
+	for _, kp := range idx {
+		if kp.line == line && (name == "" || kp.path[len(kp.path)-1] == name) {
+			return kp, true
+		}
+	}
+	return keyPos{}, false
+}
+
+func withoutIndices(path []string) []string {
+	out := make([]string, 0, len(path))
+	for _, seg := range path {
+		if _, err := strconv.Atoi(seg); err != nil {
+			out = append(out, seg)
+		}
+	}
+	return out
+}
+
+// formatPath renders a key path for messages: config.executes[1].type.
+func formatPath(path []string) string {
+	var b strings.Builder
+	for _, seg := range path {
+		if _, err := strconv.Atoi(seg); err == nil {
+			b.WriteString("[" + seg + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(seg)
+	}
+	return b.String()
+}
+
+// locateTOML indexes the table headers and keys of a TOML document. Array
+// tables ([[a.b]]) number their elements, so keys beneath them get indices.
+func locateTOML(data []byte) keyIndex {
+	var idx keyIndex
+	var table []string
+	counts := make(map[string]int) // elements seen per array table
+	depth := 0                     // open brackets of a value spanning lines
+	for n, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(stripTOMLComment(raw))
+		if depth > 0 {
+			depth += bracketDelta(line)
+			continue
+		}
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "[["):
+			base := splitTOMLKey(strings.Trim(line, "[] "))
+			key := strings.Join(base, ".")
+			table = append(slices.Clone(base), strconv.Itoa(counts[key]))
+			counts[key]++
+			idx = append(idx, keyPos{table, n + 1, strings.Index(raw, "[[") + 1})
+		case strings.HasPrefix(line, "["):
+			table = splitTOMLKey(strings.Trim(line, "[] "))
+			// A sub-table of an array table belongs to its latest element.
+			for i := len(table) - 1; i > 0; i-- {
+				if c, ok := counts[strings.Join(table[:i], ".")]; ok {
+					table = slices.Insert(table, i, strconv.Itoa(c-1))
+					break
+				}
+			}
+			idx = append(idx, keyPos{table, n + 1, strings.Index(raw, "[") + 1})
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			key = strings.TrimSpace(key)
+			path := append(slices.Clone(table), splitTOMLKey(key)...)
+			idx = append(idx, keyPos{path, n + 1, strings.Index(raw, key) + 1})
+			depth = bracketDelta(value)
+		}
+	}
+	return idx
+}
+
+// splitTOMLKey splits a dotted key, unquoting quoted segments.
+func splitTOMLKey(key string) []string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return parts
+}
+
+// stripTOMLComment drops a trailing # comment that is not inside a string.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// bracketDelta is the net number of brackets and braces a value opens, ignoring
+// those inside strings.
+func bracketDelta(s string) int {
+	delta := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			delta++
+		case c == ']' || c == '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+var yamlKey = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s:#][^:#]*?)\s*:(\s|$)`)
+
+// locateYAML indexes the mapping keys of a block-style YAML document, tracking
+// nesting by indentation. Sequence items ("- ") become indexed segments.
+func locateYAML(data []byte) keyIndex {
+	type frame struct {
+		indent int
+		seg    string
+		item   bool
+	}
+	var idx keyIndex
+	var stack []frame
+	items := make(map[string]int) // elements seen per sequence
+	scalarIndent := -1            // inside a block scalar deeper than this
+	path := func() []string {
+		p := make([]string, len(stack))
+		for i, f := range stack {
+			p[i] = f.seg
+		}
+		return p
+	}
+	for n, raw := range strings.Split(string(data), "\n") {
+		content := strings.TrimLeft(raw, " ")
+		indent := len(raw) - len(content)
+		content = strings.TrimRight(content, " \t\r")
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" {
+			continue
+		}
+		if scalarIndent >= 0 {
+			if indent > scalarIndent {
+				continue
+			}
+			scalarIndent = -1
+		}
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			for len(stack) > 0 && (stack[len(stack)-1].indent > indent ||
+				stack[len(stack)-1].indent == indent && stack[len(stack)-1].item) {
+				stack = stack[:len(stack)-1]
+			}
+			parent := strings.Join(path(), ".")
+			stack = append(stack, frame{indent: indent, seg: strconv.Itoa(items[parent]), item: true})
+			items[parent]++
+			idx = append(idx, keyPos{path(), n + 1, indent + 1})
+			rest := strings.TrimLeft(content[1:], " ")
+			indent += len(content) - len(rest)
+			content = rest
+		} else {
+			for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+				stack = stack[:len(stack)-1]
+			}
+		}
+
+		m := yamlKey.FindStringSubmatch(content)
+		if m == nil {
+			continue
+		}
+		key := strings.Trim(m[1], `"'`)
+		stack = append(stack, frame{indent: indent, seg: key})
+		idx = append(idx, keyPos{path(), n + 1, indent + 1})
+		if value := strings.TrimSpace(content[len(m[0]):]); strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			scalarIndent = indent
+		}
+	}
+	return idx
+}
+
+// locateJSON indexes the object keys of a JSON document using the decoder's
+// token stream and input offsets.
+func locateJSON(data []byte) keyIndex {
+	type frame struct {
+		object bool
+		key    string // object: the key whose value is being read
+		n      int    // array: the index of the next element
+	}
+	var idx keyIndex
+	var stack []frame
+	path := func() []string {
+		var p []string
+		for _, f := range stack {
+			if f.object {
+				p = append(p, f.key)
+			} else {
+				p = append(p, strconv.Itoa(f.n))
+			}
+		}
+		return p
+	}
+	// valueDone advances the enclosing container past a completed value.
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := &stack[len(stack)-1]; top.object {
+			top.key = ""
+		} else {
+			top.n++
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return idx
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				stack = append(stack, frame{object: true})
+			case '[':
+				stack = append(stack, frame{})
+			default:
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+		case string:
+			if top := len(stack) - 1; top >= 0 && stack[top].object && stack[top].key == "" {
+				stack[top].key = t
+				end := int(dec.InputOffset())
+				start := bytes.LastIndexByte(data[:end-1], '"')
+				line, col := offsetPosition(data, start)
+				idx = append(idx, keyPos{path(), line, col})
+				continue
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
+
+// offsetPosition converts a byte offset into a 1-based line and column.
+func offsetPosition(data []byte, offset int) (line, col int) {
+	if offset < 0 {
+		return 0, 0
+	}
+	offset = min(offset, len(data))
+	line = bytes.Count(data[:offset], []byte("\n")) + 1
+	col = offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, col
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// ExitError is returned when refresh should exit with a particular status. Code
+// is the failing step's exit code, or 1 when it has none (it could not start or
+// was killed by a signal). It unwraps to the step's *process.StepError.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string { return e.Err.Error() }
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// exitError wraps a step failure in an ExitError carrying its exit code.
+func exitError(err error) *ExitError {
+	code := 1
+	var step *process.StepError
+	if errors.As(err, &step) && step.ExitCode > 0 {
+		code = step.ExitCode
+	}
+	return &ExitError{Code: code, Err: err}
+}
+
+// OnceSummary reports a RunOnce pass.
+type OnceSummary struct {
+	// Steps lists every configured step in order, including those that did not
+	// run (StatePending).
+	Steps    []process.StepResult
+	Duration time.Duration
+}
+
+// String renders the summary as a table with one step per line, followed by
+// the overall outcome.
+func (s OnceSummary) String() string {
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tTYPE\tRESULT\tEXIT\tTIME")
+	var failed *process.StepResult
+	for i, step := range s.Steps {
+		result, exit, took := string(step.State), "-", "-"
+		switch step.State {
+		case process.StatePending:
+			result = "skipped"
+		case process.StateRunning:
+			result = "started"
+		case process.StateSkipped:
+			result = "cached"
+		}
+		if step.ExitCode >= 0 {
+			exit = fmt.Sprint(step.ExitCode)
+		}
+		if step.State != process.StatePending {
+			took = step.Duration.Round(time.Millisecond).String()
+		}
+		if failed == nil && (step.State == process.StateFailed || step.State == process.StateKilled) {
+			failed = &s.Steps[i]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", step.Name, step.Type, result, exit, took)
+	}
+	tw.Flush()
+	switch {
+	case failed != nil && failed.ExitCode >= 0:
+		fmt.Fprintf(&b, "FAIL %s exited %d after %s\n", failed.Name, failed.ExitCode, s.Duration.Round(time.Millisecond))
+	case failed != nil:
+		fmt.Fprintf(&b, "FAIL %s %s after %s\n", failed.Name, failed.State, s.Duration.Round(time.Millisecond))
+	default:
+		fmt.Fprintf(&b, "ok in %s\n", s.Duration.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+// RunOnce runs the configured steps a single time and returns, for CI jobs
+// that reuse a refresh config: background processes are started, once and
+// blocking steps run to completion, and the primary is skipped unless
+// Config.OncePrimary is set, in which case it runs until it exits. The watcher
+// is not started. Background processes are stopped before RunOnce returns.
+//
+// The pass stops at the first failing step; the error is then an *ExitError
+// carrying that step's exit code. A cancelled ctx (or Stop) aborts the pass
+// and returns the context's error. The summary is valid either way.
+func (engine *Engine) RunOnce(ctx context.Context) (OnceSummary, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	engine.ctx = ctx
+	engine.cancel = cancel
+
+	slog.Info("refresh running once", "primary", engine.Config.OncePrimary)
+	engine.emitReload(ReloadEvent{Kind: ReloadStarted, Trigger: TriggerStartup})
+	start := time.Now()
+	steps, err := engine.ProcessManager.RunOnce(ctx, engine.Config.OncePrimary)
+	engine.ProcessManager.Shutdown()
+	summary := OnceSummary{Steps: steps, Duration: time.Since(start)}
+	engine.cycle++
+
+	done := ReloadEvent{Trigger: TriggerStartup, Duration: summary.Duration}
+	switch {
+	case err == nil:
+		done.Kind = ReloadSucceeded
+		engine.emitReload(done)
+		return summary, nil
+	case ctx.Err() != nil:
+		return summary, ctx.Err()
+	}
+	done.Kind = ReloadFailed
+	done.Err = err
+	var step *process.StepError
+	if errors.As(err, &step) {
+		done.Process = step.Name
+	}
+	engine.emitReload(done)
+	engine.summarizeDiagnostics()
+	return summary, exitError(err)
+}
@@ -0,0 +1,96 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// TestRunOnceStopsAtFirstFailure checks a failing step ends the pass with its
+// exit code, later steps and the primary are reported as skipped, and the
+// background process is stopped.
+func TestRunOnceStopsAtFirstFailure(t *testing.T) {
+	dir := t.TempDir()
+	eng, err := NewEngineFromConfig(Config{
+		RootPath:         dir,
+		LogLevel:         "mute",
+		BackgroundStruct: Execute{Name: "db", Cmd: "sleep 30"},
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "touch built", Type: Blocking},
+			{Name: "test", Cmd: "exit 3", Type: Blocking},
+			{Name: "lint", Cmd: "touch linted", Type: Blocking},
+			{Name: "app", Cmd: "sleep 30", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := eng.RunOnce(context.Background())
+	var exit *ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Fatalf("err = %v, want an ExitError with code 3", err)
+	}
+	var step *process.StepError
+	if !errors.As(err, &step) || step.Name != "test" {
+		t.Errorf("err does not unwrap to the test step: %v", err)
+	}
+
+	want := map[string]process.ProcessState{
+		"db":    process.StateRunning,
+		"build": process.StateExited,
+		"test":  process.StateFailed,
+		"lint":  process.StatePending,
+		"app":   process.StatePending,
+	}
+	if len(summary.Steps) != len(want) {
+		t.Fatalf("steps = %+v", summary.Steps)
+	}
+	for _, s := range summary.Steps {
+		if s.State != want[s.Name] {
+			t.Errorf("%s: state %s, want %s", s.Name, s.State, want[s.Name])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "linted")); err == nil {
+		t.Error("a step after the failure ran")
+	}
+	if out := summary.String(); !strings.Contains(out, "FAIL test exited 3") {
+		t.Errorf("summary = %q", out)
+	}
+	for _, info := range eng.Processes() {
+		if info.Name == "db" && info.State == process.StateRunning {
+			t.Error("background process still running after RunOnce")
+		}
+	}
+}
+
+// TestRunOncePrimaryRunsToExit checks OncePrimary treats the primary as a
+// final blocking step whose success ends the pass.
+func TestRunOncePrimaryRunsToExit(t *testing.T) {
+	eng, err := NewEngineFromConfig(Config{
+		RootPath:    t.TempDir(),
+		LogLevel:    "mute",
+		OncePrimary: true,
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "true", Type: Blocking},
+			{Name: "tests", Cmd: "sleep 0.1", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := eng.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+	last := summary.Steps[len(summary.Steps)-1]
+	if last.Name != "tests" || last.State != process.StateExited || last.ExitCode != 0 {
+		t.Errorf("primary step = %+v, want exited 0", last)
+	}
+}
@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// Setting is a top-level config key, as listed by `refresh config print`.
+// Settings with an Env variable can be overridden from the environment.
+type Setting struct {
+	// Key is the config key, e.g. "log_level" or "ignore.dir".
+	Key string
+	// Env is the environment variable overriding the key, e.g.
+	// REFRESH_LOG_LEVEL; empty when the key can only be set in a config.
+	Env string
+
+	field func(*Config) any
+}
+
+// Settings lists the config settings in display order. Config values are
+// resolved with the precedence defaults < config file < profile < environment
+// < Overrides, which is where the CLI puts explicitly set flags.
+var Settings = []Setting{
+	{"root_path", "REFRESH_ROOT_PATH", func(c *Config) any { return &c.RootPath }},
+	{"log_level", "REFRESH_LOG_LEVEL", func(c *Config) any { return &c.LogLevel }},
+	{"debounce", "REFRESH_DEBOUNCE", func(c *Config) any { return &c.Debounce }},
+	{"enable_pause", "REFRESH_ENABLE_PAUSE", func(c *Config) any { return &c.EnablePause }},
+	{"log_buffer", "REFRESH_LOG_BUFFER", func(c *Config) any { return &c.LogBuffer }},
+	{"metrics_interval", "REFRESH_METRICS_INTERVAL", func(c *Config) any { return &c.MetricsInterval }},
+	{"ignore.dir", "REFRESH_IGNORE_DIR", func(c *Config) any { return &c.Ignore.Dir }},
+	{"ignore.file", "REFRESH_IGNORE_FILE", func(c *Config) any { return &c.Ignore.File }},
+	{"ignore.watched_extension", "REFRESH_WATCHED_EXTENSION", func(c *Config) any { return &c.Ignore.WatchedExten }},
+	{"ignore.git", "REFRESH_IGNORE_GIT", func(c *Config) any { return &c.Ignore.IgnoreGit }},
+	{"exit_on", "REFRESH_EXIT_ON", func(c *Config) any { return (*string)(&c.ExitOn) }},
+	{"once_primary", "REFRESH_ONCE_PRIMARY", func(c *Config) any { return &c.OncePrimary }},
+	{"shell", "REFRESH_SHELL", func(c *Config) any { return &c.Shell }},
+	{"exec_list", "REFRESH_EXEC", func(c *Config) any { return &c.ExecList }},
+	{"background", "", func(c *Config) any { return &c.BackgroundStruct }},
+	{"executes", "", func(c *Config) any { return &c.ExecStruct }},
+}
+
+// Value returns the setting's value in cfg.
+func (s Setting) Value(cfg *Config) any {
+	switch p := s.field(cfg).(type) {
+	case *string:
+		return *p
+	case *int:
+		return *p
+	case *bool:
+		return *p
+	case *[]string:
+		return *p
+	case *process.Execute:
+		return *p
+	case *[]process.Execute:
+		return *p
+	}
+	return nil
+}
+
+// isSet reports whether the setting has a non-zero value in cfg.
+func (s Setting) isSet(cfg *Config) bool {
+	switch p := s.field(cfg).(type) {
+	case *string:
+		return *p != ""
+	case *int:
+		return *p != 0
+	case *bool:
+		return *p
+	case *[]string:
+		return len(*p) > 0
+	case *process.Execute:
+		return p.Command() != ""
+	case *[]process.Execute:
+		return len(*p) > 0
+	}
+	return false
+}
+
+// set parses raw, an environment or override value, into the setting. Lists
+// are comma-separated.
+func (s Setting) set(cfg *Config, raw string) error {
+	switch p := s.field(cfg).(type) {
+	case *string:
+		*p = raw
+	case *int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*p = b
+	case *[]string:
+		*p = nil
+		for item := range strings.SplitSeq(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*p = append(*p, item)
+			}
+		}
+	}
+	return nil
+}
+
+// ApplyEnv overrides config values from the REFRESH_* environment variables
+// listed in Settings; empty variables are ignored. Configs loaded from a file
+// or string already have it applied. REFRESH_EXEC replaces the executes with
+// an exec list.
+func (c *Config) ApplyEnv() error {
+	for _, s := range Settings {
+		if s.Env == "" {
+			continue
+		}
+		raw := os.Getenv(s.Env)
+		if raw == "" {
+			continue
+		}
+		if err := s.set(c, raw); err != nil {
+			return fmt.Errorf("%s: %w", s.Env, err)
+		}
+		if s.Key == "exec_list" {
+			c.ExecStruct = nil
+		}
+		c.setSource(s.Key, "env "+s.Env)
+	}
+	return nil
+}
+
+// Overrides are config values a caller sets on top of a config file, its
+// profile and the environment, such as the CLI's explicitly set flags. An
+// engine built with them applies them on every load of its file, hot reloads
+// included. Unlike exported REFRESH_* variables, they do not reach the
+// environment of the commands refresh runs.
+type Overrides struct {
+	// Profile selects a profile, ahead of $REFRESH_PROFILE and the file's
+	// profile key.
+	Profile string
+	// Values are settings by Settings key, written as for their environment
+	// variable (lists comma-separated). An exec_list value replaces the
+	// executes.
+	Values map[string]string
+	// Sources names where each of Values came from, by key, as reported by
+	// Config.Source, e.g. "flag -l"; "override" when not given.
+	Sources map[string]string
+	// ConfigRelativeRoot resolves a relative root_path from the config file,
+	// its profile or the default against the file's directory rather than the
+	// working directory, as for a config discovered in a parent directory.
+	ConfigRelativeRoot bool
+}
+
+// ApplyOverrides sets the values of o, on top of whatever set them before.
+// Configs loaded with NewEngineFromFile already have them applied. Unknown
+// keys are an error.
+func (c *Config) ApplyOverrides(o Overrides) error {
+	for key, raw := range o.Values {
+		i := slices.IndexFunc(Settings, func(s Setting) bool { return s.Key == key })
+		if i < 0 || Settings[i].Env == "" {
+			return fmt.Errorf("%s cannot be overridden", key)
+		}
+		if err := Settings[i].set(c, raw); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		if key == "exec_list" {
+			c.ExecStruct = nil
+		}
+		c.setSource(key, cmp.Or(o.Sources[key], "override"))
+	}
+	return nil
+}
+
+// Source reports where the value of a setting (a Settings key) came from:
+// "file <path>", "profile <name>", "env <VAR>", the source given in Overrides,
+// or "default" when nothing set it.
+func (c *Config) Source(key string) string {
+	if src, ok := c.sources[key]; ok {
+		return src
+	}
+	return "default"
+}
+
+func (c *Config) setSource(key, source string) {
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[key] = source
+}
+
+// recordSources attributes every setting layer sets to source.
+func (c *Config) recordSources(layer *Config, source string) {
+	for _, s := range Settings {
+		if s.isSet(layer) {
+			c.setSource(s.Key, source)
+		}
+	}
+}
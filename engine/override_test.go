@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestEnvOverridesConfigFile checks REFRESH_* variables override the file and
+// its profile, and that each setting reports where its value came from.
+func TestEnvOverridesConfigFile(t *testing.T) {
+	t.Setenv(ProfileEnv, "test")
+	t.Setenv("REFRESH_LOG_LEVEL", "error")
+	t.Setenv("REFRESH_IGNORE_DIR", "vendor, node_modules")
+	t.Setenv("REFRESH_DEBOUNCE", "")
+	path := writeConfig(t, "refresh.toml", profileConfig)
+	eng, err := NewEngineFromTOML(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &eng.Config
+	if cfg.LogLevel != "error" || cfg.Debounce != 200 {
+		t.Errorf("log_level %q, debounce %d", cfg.LogLevel, cfg.Debounce)
+	}
+	if want := []string{"vendor", "node_modules"}; !slices.Equal(cfg.Ignore.Dir, want) {
+		t.Errorf("ignore.dir = %v, want %v", cfg.Ignore.Dir, want)
+	}
+	sources := map[string]string{
+		"root_path":    "file " + path,
+		"log_level":    "env REFRESH_LOG_LEVEL",
+		"debounce":     "profile test",
+		"ignore.dir":   "env REFRESH_IGNORE_DIR",
+		"enable_pause": "default",
+	}
+	for key, want := range sources {
+		if got := cfg.Source(key); !strings.HasSuffix(got, want) {
+			t.Errorf("Source(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	t.Setenv("REFRESH_DEBOUNCE", "soon")
+	if _, err := NewEngineFromTOML(path); err == nil || !strings.Contains(err.Error(), "REFRESH_DEBOUNCE") {
+		t.Errorf("err = %v, want an invalid REFRESH_DEBOUNCE", err)
+	}
+}
+
+// TestOverridesConfigFile checks Overrides take precedence over the
+// environment, select the profile, and still apply when the file is
+// reloaded.
+func TestOverridesConfigFile(t *testing.T) {
+	t.Setenv(ProfileEnv, "dev")
+	t.Setenv("REFRESH_LOG_LEVEL", "error")
+	path := writeConfig(t, "refresh.toml", profileConfig)
+	overrides := Overrides{
+		Profile: "test",
+		Values:  map[string]string{"log_level": "warn", "ignore.dir": "tmp"},
+		Sources: map[string]string{"log_level": "flag -l"},
+	}
+	eng, err := NewEngineFromFile(path, overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(cfg Config) {
+		t.Helper()
+		if cfg.Profile != "test" || cfg.Debounce != 200 || cfg.LogLevel != "warn" || !slices.Equal(cfg.Ignore.Dir, []string{"tmp"}) {
+			t.Errorf("profile %q, debounce %d, log_level %q, ignore.dir %v", cfg.Profile, cfg.Debounce, cfg.LogLevel, cfg.Ignore.Dir)
+		}
+		if got := cfg.Source("log_level"); got != "flag -l" {
+			t.Errorf("Source(log_level) = %q, want flag -l", got)
+		}
+		if got := cfg.Source("ignore.dir"); got != "override" {
+			t.Errorf("Source(ignore.dir) = %q, want override", got)
+		}
+	}
+	check(eng.Config)
+	reloaded, _, err := loadConfigFile(eng.configPath, eng.overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(reloaded)
+
+	overrides.Values = map[string]string{"debounce": "soon"}
+	if _, err := NewEngineFromFile(path, overrides); err == nil || !strings.Contains(err.Error(), "debounce") {
+		t.Errorf("err = %v, want an invalid debounce", err)
+	}
+}
+
+// TestConfigRelativeRoot checks a relative root_path from the file resolves
+// against the file's directory when asked to, and one from the environment
+// still against the working directory.
+func TestConfigRelativeRoot(t *testing.T) {
+	path := writeConfig(t, "refresh.toml", `
+[config]
+root_path = "app"
+
+[[config.executes]]
+cmd = "./server"
+type = "primary"
+`)
+	rel := Overrides{ConfigRelativeRoot: true}
+	eng, err := NewEngineFromFile(path, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(filepath.Dir(path), "app"); eng.Config.RootPath != want {
+		t.Errorf("root_path = %q, want %q", eng.Config.RootPath, want)
+	}
+	if eng, err = NewEngineFromFile(path, Overrides{}); err != nil {
+		t.Fatal(err)
+	}
+	if eng.Config.RootPath != "app" {
+		t.Errorf("without ConfigRelativeRoot: root_path = %q, want %q", eng.Config.RootPath, "app")
+	}
+	t.Setenv("REFRESH_ROOT_PATH", "other")
+	if eng, err = NewEngineFromFile(path, rel); err != nil {
+		t.Fatal(err)
+	}
+	if eng.Config.RootPath != "other" {
+		t.Errorf("from the environment: root_path = %q, want %q", eng.Config.RootPath, "other")
+	}
+}
@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/atterpac/refresh/process"
+	"gopkg.in/yaml.v2"
+)
+
+// ProfileEnv names the environment variable that selects a config profile. It
+// takes precedence over the profile named in the file.
+const ProfileEnv = "REFRESH_PROFILE"
+
+// Profile is a named set of overrides layered onto the base config when it is
+// selected, so one file can serve local development, tests and demos. Set
+// values replace the base ones; executes are merged by name.
+type Profile struct {
+	RootPath    string `toml:"root_path"    yaml:"root_path"    json:"root_path"`
+	LogLevel    string `toml:"log_level"    yaml:"log_level"    json:"log_level"`
+	Debounce    *int   `toml:"debounce"     yaml:"debounce"     json:"debounce"`
+	EnablePause *bool  `toml:"enable_pause" yaml:"enable_pause" json:"enable_pause"`
+	LogBuffer   *int   `toml:"log_buffer"   yaml:"log_buffer"   json:"log_buffer"`
+	// Ignore extends the base ignore lists; git = true also enables .gitignore.
+	Ignore Ignore `toml:"ignore" yaml:"ignore" json:"ignore"`
+	// Background replaces the base background command.
+	Background *process.Execute `toml:"background" yaml:"background" json:"background"`
+	// Executes replace the base execute with the same name in place, or are
+	// appended when the name is new.
+	Executes []process.Execute `toml:"executes" yaml:"executes" json:"executes"`
+	// Remove drops base executes by name.
+	Remove []string `toml:"remove" yaml:"remove" json:"remove"`
+}
+
+// apply layers the profile onto cfg.
+func (p Profile) apply(cfg *Config) {
+	if p.RootPath != "" {
+		cfg.RootPath = p.RootPath
+	}
+	if p.LogLevel != "" {
+		cfg.LogLevel = p.LogLevel
+	}
+	if p.Debounce != nil {
+		cfg.Debounce = *p.Debounce
+	}
+	if p.EnablePause != nil {
+		cfg.EnablePause = *p.EnablePause
+	}
+	if p.LogBuffer != nil {
+		cfg.LogBuffer = *p.LogBuffer
+	}
+	cfg.Ignore.Dir = append(cfg.Ignore.Dir, p.Ignore.Dir...)
+	cfg.Ignore.File = append(cfg.Ignore.File, p.Ignore.File...)
+	cfg.Ignore.WatchedExten = append(cfg.Ignore.WatchedExten, p.Ignore.WatchedExten...)
+	cfg.Ignore.IgnoreGit = cfg.Ignore.IgnoreGit || p.Ignore.IgnoreGit
+	if p.Background != nil {
+		cfg.BackgroundStruct = *p.Background
+	}
+	if len(p.Executes) == 0 && len(p.Remove) == 0 {
+		return
+	}
+	// Merging by name needs the struct form of a base given as an exec list.
+	if len(cfg.ExecStruct) == 0 && len(cfg.ExecList) > 0 {
+		cfg.ExecStruct = execListToSpecs(cfg.ExecList)
+	}
+	execs := mergeExecutes(cfg.ExecStruct, p.Executes)
+	cfg.ExecStruct = slices.DeleteFunc(execs, func(exe process.Execute) bool {
+		return slices.Contains(p.Remove, specName(exe))
+	})
+}
+
+// applyProfile applies the selected profile: the overrides' Profile, else
+// $REFRESH_PROFILE, else the file's profile key. Naming a profile the config
+// does not define is an error.
+func (engine *Engine) applyProfile() error {
+	name := cmp.Or(engine.overrides.Profile, os.Getenv(ProfileEnv), engine.Config.Profile)
+	if name == "" {
+		return nil
+	}
+	profile, ok := engine.Config.Profiles[name]
+	if !ok {
+		defined := slices.Sorted(maps.Keys(engine.Config.Profiles))
+		if len(defined) == 0 {
+			return fmt.Errorf("profile %q selected but the config defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (defined: %s)", name, strings.Join(defined, ", "))
+	}
+	engine.Config.Profile = name
+	profile.apply(&engine.Config)
+	var set Config
+	profile.apply(&set)
+	engine.Config.recordSources(&set, "profile "+name)
+	return nil
+}
+
+// mergeExecutes replaces executes of base with same-named ones from over, in
+// place, and appends the rest.
+func mergeExecutes(base, over []process.Execute) []process.Execute {
+	out := slices.Clone(base)
+	for _, exe := range over {
+		i := slices.IndexFunc(out, func(b process.Execute) bool { return specName(b) == specName(exe) })
+		if i >= 0 {
+			out[i] = exe
+		} else {
+			out = append(out, exe)
+		}
+	}
+	return out
+}
+
+// decoder decodes one config document into engine. Keys the document does not
+// contain are left as they are, which is what lets a file layer onto the one
+// it extends.
+type decoder func(data []byte, engine *Engine) error
+
+func decodeTOML(data []byte, engine *Engine) error {
+	_, err := toml.Decode(string(data), engine)
+	return err
+}
+
+func decodeYAML(data []byte, engine *Engine) error {
+	return yaml.Unmarshal(data, engine)
+}
+
+func decodeJSON(data []byte, engine *Engine) error {
+	return json.Unmarshal(data, engine)
+}
+
+// decoderFor picks a decoder by file extension, defaulting to TOML.
+func decoderFor(path string) decoder {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return decodeYAML
+	case ".json":
+		return decodeJSON
+	default:
+		return decodeTOML
+	}
+}
+
+// decodeLayers decodes a config document into engine on top of the file it
+// extends, if any, recursively. A relative extends path is resolved against
+// dir, the document's directory. Scalars set in the document override the
+// base, executes are merged by name and profiles by profile name. chain lists
+// the files being loaded, to reject cycles.
+func (engine *Engine) decodeLayers(data []byte, decode decoder, dir string, chain []string) error {
+	var layer Engine
+	if err := decode(data, &layer); err != nil {
+		return err
+	}
+	source := "string"
+	if len(chain) > 0 {
+		source = "file " + chain[len(chain)-1]
+	}
+	if layer.Config.Extends == "" {
+		if err := decode(data, engine); err != nil {
+			return err
+		}
+		engine.Config.recordSources(&layer.Config, source)
+		return nil
+	}
+
+	path := expandVars(layer.Config.Extends, func(name string) (string, bool) {
+		if name == "CONFIG_DIR" {
+			return dir, true
+		}
+		return os.LookupEnv(name)
+	})
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if slices.Contains(chain, path) {
+		return fmt.Errorf("config extends cycle: %s", strings.Join(append(chain, path), " -> "))
+	}
+	engine.loaded = append(engine.loaded, path)
+	base, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("extends: %w", err)
+	}
+	if err := engine.decodeLayers(base, decoderFor(path), filepath.Dir(path), append(chain, path)); err != nil {
+		return fmt.Errorf("extends %s: %w", path, err)
+	}
+
+	// Decode the document over the base into fresh lists, so decoders that reuse
+	// a slice's backing array cannot clobber the base entries being merged.
+	execs, profiles := engine.Config.ExecStruct, engine.Config.Profiles
+	engine.Config.ExecStruct, engine.Config.Profiles = nil, nil
+	if err := decode(data, engine); err != nil {
+		return err
+	}
+	engine.Config.ExecStruct = mergeExecutes(execs, layer.Config.ExecStruct)
+	merged := maps.Clone(profiles)
+	if merged == nil && len(layer.Config.Profiles) > 0 {
+		merged = make(map[string]Profile, len(layer.Config.Profiles))
+	}
+	maps.Copy(merged, layer.Config.Profiles)
+	engine.Config.Profiles = merged
+	engine.Config.recordSources(&layer.Config, source)
+	return nil
+}
+
+// loadDocument decodes a config document with its extends chain, applies the
+// selected profile, environment and engine overrides, and expands variables —
+// everything that turns a file or string into the config the engine runs. dir
+// is the document's directory.
+func (engine *Engine) loadDocument(data []byte, decode decoder, dir string, chain []string) error {
+	if err := engine.decodeLayers(data, decode, dir, chain); err != nil {
+		return err
+	}
+	if err := engine.applyProfile(); err != nil {
+		return err
+	}
+	if err := engine.Config.ApplyEnv(); err != nil {
+		return err
+	}
+	if err := engine.Config.ApplyOverrides(engine.overrides); err != nil {
+		return err
+	}
+	engine.expandConfig(dir)
+	return nil
+}
+
+// loadFile is loadDocument for a config file.
+func (engine *Engine) loadFile(path string, decode decoder) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	engine.loaded = []string{abs}
+	return engine.loadDocument(data, decode, filepath.Dir(abs), []string{abs})
+}
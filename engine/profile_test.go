@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/atterpac/refresh/process"
+)
+
+const profileConfig = `
+[config]
+root_path = "."
+log_level = "info"
+debounce = 1000
+profile = "dev"
+
+[[config.executes]]
+name = "build"
+cmd = "go build -o ./bin/app ."
+type = "blocking"
+
+[[config.executes]]
+name = "lint"
+cmd = "golangci-lint run"
+type = "blocking"
+
+[[config.executes]]
+name = "app"
+cmd = "./bin/app"
+type = "primary"
+
+[config.profiles.dev]
+log_level = "debug"
+
+[config.profiles.test]
+debounce = 200
+remove = ["lint"]
+
+[[config.profiles.test.executes]]
+name = "app"
+cmd = "go test ./..."
+type = "primary"
+
+[[config.profiles.test.executes]]
+name = "coverage"
+cmd = "go tool cover -func=cover.out"
+type = "blocking"
+`
+
+func execNames(execs []process.Execute) []string {
+	names := make([]string, len(execs))
+	for i, exe := range execs {
+		names[i] = specName(exe)
+	}
+	return names
+}
+
+// TestProfileSelection checks the file's default profile applies, that
+// REFRESH_PROFILE overrides it, and that executes are replaced in place,
+// appended and removed by name.
+func TestProfileSelection(t *testing.T) {
+	t.Setenv(ProfileEnv, "")
+	eng := &Engine{}
+	if err := eng.StringtoConfigTOML(profileConfig); err != nil {
+		t.Fatal(err)
+	}
+	if eng.Config.LogLevel != "debug" || eng.Config.Debounce != 1000 {
+		t.Errorf("dev profile: log_level %q, debounce %d", eng.Config.LogLevel, eng.Config.Debounce)
+	}
+	if got := strings.Join(execNames(eng.Config.ExecStruct), ","); got != "build,lint,app" {
+		t.Errorf("dev executes = %s", got)
+	}
+
+	t.Setenv(ProfileEnv, "test")
+	eng = &Engine{}
+	if err := eng.StringtoConfigTOML(profileConfig); err != nil {
+		t.Fatal(err)
+	}
+	if eng.Config.Profile != "test" || eng.Config.LogLevel != "info" || eng.Config.Debounce != 200 {
+		t.Errorf("test profile: profile %q, log_level %q, debounce %d", eng.Config.Profile, eng.Config.LogLevel, eng.Config.Debounce)
+	}
+	if got := strings.Join(execNames(eng.Config.ExecStruct), ","); got != "build,app,coverage" {
+		t.Errorf("test executes = %s, want build,app,coverage", got)
+	}
+	if app := eng.Config.ExecStruct[1]; app.Cmd != "go test ./..." {
+		t.Errorf("app not replaced: %+v", app)
+	}
+
+	t.Setenv(ProfileEnv, "prod")
+	err := (&Engine{}).StringtoConfigTOML(profileConfig)
+	if err == nil || !strings.Contains(err.Error(), "dev, test") {
+		t.Errorf("unknown profile err = %v, want the defined profiles listed", err)
+	}
+}
+
+// TestConfigExtends checks a file layered on another across formats: scalars
+// override, executes merge by name, and the base's profiles stay selectable.
+func TestConfigExtends(t *testing.T) {
+	t.Setenv(ProfileEnv, "ci")
+	dir := t.TempDir()
+	base := `config:
+  root_path: .
+  debounce: 500
+  ignore:
+    dir: [.git]
+  executes:
+    - name: build
+      cmd: make build
+      type: blocking
+    - name: app
+      cmd: ./app
+      type: primary
+  profiles:
+    ci:
+      log_level: error
+`
+	if err := os.MkdirAll(filepath.Join(dir, "shared"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shared", "base.yaml"), []byte(base), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	child := filepath.Join(dir, "svc", "refresh.toml")
+	if err := os.MkdirAll(filepath.Dir(child), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := os.WriteFile(child, []byte(`
+[config]
+extends = "../shared/base.yaml"
+debounce = 100
+
+[[config.executes]]
+name = "app"
+cmd = "./app -debug"
+type = "primary"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eng, err := NewEngineFromTOML(child)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := eng.Config
+	if cfg.Debounce != 100 || cfg.RootPath != "." || cfg.LogLevel != "error" {
+		t.Errorf("debounce %d, root %q, log_level %q", cfg.Debounce, cfg.RootPath, cfg.LogLevel)
+	}
+	if len(cfg.Ignore.Dir) != 1 || cfg.Ignore.Dir[0] != ".git" {
+		t.Errorf("ignore.dir = %v, want the base's", cfg.Ignore.Dir)
+	}
+	if got := strings.Join(execNames(cfg.ExecStruct), ","); got != "build,app" || cfg.ExecStruct[1].Cmd != "./app -debug" {
+		t.Errorf("executes = %+v", cfg.ExecStruct)
+	}
+}
+
+func TestConfigExtendsCycle(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.toml")
+	b := filepath.Join(dir, "b.toml")
+	if err := os.WriteFile(a, []byte("[config]\nextends = \"b.toml\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("[config]\nextends = \"a.toml\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewEngineFromTOML(a)
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("err = %v, want an extends cycle", err)
+	}
+}
@@ -0,0 +1,372 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyConfig configures the live-reload proxy. When Listen is set, refresh
+// serves a reverse proxy to Target there that injects a small script into HTML
+// pages; the script reloads the page once a reload cycle succeeds and the new
+// primary accepts connections, and overlays the build errors when it fails.
+type ProxyConfig struct {
+	// Listen is the address the proxy serves on, e.g. ":3000".
+	Listen string `toml:"listen" yaml:"listen" json:"listen"`
+	// Target is the primary's address, e.g. "localhost:8080" or
+	// "http://localhost:8080".
+	Target string `toml:"target" yaml:"target" json:"target"`
+}
+
+const (
+	// proxyPrefix namespaces the proxy's own endpoints on the proxied site.
+	proxyPrefix = "/__refresh/"
+	// proxyReadyTimeout bounds the wait for a restarted primary to accept
+	// connections before browsers are reloaded anyway.
+	proxyReadyTimeout = 10 * time.Second
+)
+
+// proxyTarget parses a ProxyConfig.Target, defaulting the scheme to http.
+func proxyTarget(target string) (*url.URL, error) {
+	if target == "" {
+		return nil, errors.New("proxy.target is required when proxy.listen is set")
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("proxy.target: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy.target %q has no host", target)
+	}
+	return u, nil
+}
+
+// buildFailure is what the error overlay shows for a failed reload cycle.
+type buildFailure struct {
+	Process     string   `json:"process"`
+	Error       string   `json:"error"`
+	Diagnostics []string `json:"diagnostics"`
+}
+
+// liveReload is the proxy server and the set of connected browsers.
+type liveReload struct {
+	target *url.URL
+	proxy  *httputil.ReverseProxy
+
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+	// failure is the last failed cycle, nil after a success; browsers that
+	// connect while it is set are shown the overlay straight away.
+	failure *buildFailure
+	// cycle counts the reload cycles that ended; a success reloads browsers
+	// only if no later cycle has ended by the time its primary is ready.
+	cycle uint64
+	// cancelWait stops the readiness wait of the last successful cycle.
+	cancelWait context.CancelFunc
+}
+
+// startProxy serves the live-reload proxy until ctx is cancelled, and follows
+// reload events to notify browsers. The listener is opened before returning so
+// a bad address fails startup.
+func (engine *Engine) startProxy(ctx context.Context) error {
+	cfg := engine.Config.Proxy
+	target, err := proxyTarget(cfg.Target)
+	if err != nil {
+		return err
+	}
+	lr := &liveReload{target: target, clients: make(map[chan string]struct{})}
+	lr.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(target)
+			r.SetXForwarded()
+			r.Out.Host = r.In.Host
+			// Uncompressed responses let the script be injected into HTML.
+			r.Out.Header.Del("Accept-Encoding")
+		},
+		ModifyResponse: injectScript,
+		ErrorHandler:   lr.unavailable,
+	}
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return fmt.Errorf("live-reload proxy: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(proxyPrefix+"events", lr.serveEvents)
+	mux.HandleFunc(proxyPrefix+"ready", lr.serveReady)
+	mux.HandleFunc(proxyPrefix+"livereload.js", serveScript)
+	mux.Handle("/", lr.proxy)
+	srv := &http.Server{Handler: mux, BaseContext: func(net.Listener) context.Context { return ctx }}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("live-reload proxy stopped", "err", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go lr.follow(ctx, engine)
+	slog.Info("live-reload proxy listening", "addr", ln.Addr().String(), "target", target.String())
+	return nil
+}
+
+// follow turns reload cycle events into browser notifications: a success
+// reloads pages once the new primary accepts connections, a failure shows the
+// error overlay. The wait for the primary runs on its own, so it never holds
+// up later events.
+func (lr *liveReload) follow(ctx context.Context, engine *Engine) {
+	events := engine.Subscribe(ctx, EventFilter{Kinds: []EventKind{KindReload}})
+	for ev := range events {
+		switch ev.Reload.Kind {
+		case ReloadSucceeded:
+			lr.mu.Lock()
+			cycle := lr.endCycle()
+			waitCtx, cancel := context.WithCancel(ctx)
+			lr.cancelWait = cancel
+			lr.mu.Unlock()
+			go lr.reloadWhenReady(waitCtx, cycle)
+		case ReloadFailed:
+			failure := &buildFailure{Process: ev.Reload.Process}
+			if ev.Reload.Err != nil {
+				failure.Error = ev.Reload.Err.Error()
+			}
+			for _, d := range engine.ProcessManager.Diagnostics() {
+				failure.Diagnostics = append(failure.Diagnostics, d.String())
+			}
+			lr.mu.Lock()
+			lr.endCycle()
+			lr.failure = failure
+			lr.broadcast(failureEvent(failure))
+			lr.mu.Unlock()
+		}
+	}
+}
+
+// endCycle records the end of a reload cycle, stopping the readiness wait of
+// an earlier one, and returns its number. lr.mu must be held.
+func (lr *liveReload) endCycle() uint64 {
+	if lr.cancelWait != nil {
+		lr.cancelWait()
+		lr.cancelWait = nil
+	}
+	lr.cycle++
+	return lr.cycle
+}
+
+// reloadWhenReady reloads browsers once the primary accepts connections,
+// unless a later cycle has ended by then.
+func (lr *liveReload) reloadWhenReady(ctx context.Context, cycle uint64) {
+	waitReady(ctx, dialAddr(lr.target))
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	if lr.cycle != cycle || ctx.Err() != nil {
+		return
+	}
+	lr.failure = nil
+	lr.broadcast("event: reload\ndata: {}\n\n")
+}
+
+// dialAddr returns the host:port to dial for u, with the scheme's default port
+// when u gives none.
+func dialAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// waitReady polls addr until it accepts a TCP connection, ctx ends, or
+// proxyReadyTimeout passes.
+func waitReady(ctx context.Context, addr string) {
+	deadline := time.Now().Add(proxyReadyTimeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	slog.Warn("live-reload proxy: target not accepting connections, reloading browsers anyway", "target", addr)
+}
+
+func failureEvent(f *buildFailure) string {
+	data, _ := json.Marshal(f)
+	return "event: failure\ndata: " + string(data) + "\n\n"
+}
+
+// broadcast sends an SSE message to every connected browser, dropping it for
+// any that is not keeping up. lr.mu must be held.
+func (lr *liveReload) broadcast(msg string) {
+	for ch := range lr.clients {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// serveEvents is the Server-Sent Events stream the injected script listens to.
+func (lr *liveReload) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan string, 4)
+	lr.mu.Lock()
+	lr.clients[ch] = struct{}{}
+	if lr.failure != nil {
+		ch <- failureEvent(lr.failure)
+	}
+	lr.mu.Unlock()
+	defer func() {
+		lr.mu.Lock()
+		delete(lr.clients, ch)
+		lr.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-ch:
+			io.WriteString(w, msg)
+			flusher.Flush()
+		}
+	}
+}
+
+// unavailable answers while the primary is down (restarting or crashed) with a
+// page that polls the ready endpoint and reloads itself once the primary is
+// back, whether or not a reload cycle brought it back. It carries the script
+// too, so a failed cycle shows its overlay there.
+func (lr *liveReload) unavailable(w http.ResponseWriter, r *http.Request, err error) {
+	slog.Debug("live-reload proxy: target unavailable", "err", err)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusBadGateway)
+	fmt.Fprintf(w, "<!doctype html><title>refresh</title><body><p>Waiting for %s&hellip;</p>%s%s</body>",
+		html.EscapeString(lr.target.Host), waitScript, scriptTag)
+}
+
+// serveReady answers 204 when the primary accepts connections and 503 when it
+// does not.
+func (lr *liveReload) serveReady(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-cache")
+	conn, err := net.DialTimeout("tcp", dialAddr(lr.target), 200*time.Millisecond)
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	conn.Close()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+const scriptTag = `<script src="` + proxyPrefix + `livereload.js"></script>`
+
+// waitScript reloads the waiting page once the ready endpoint reports the
+// primary is back.
+const waitScript = `<script>setInterval(function () {
+  fetch("` + proxyPrefix + `ready").then(function (r) { if (r.ok) location.reload(); }, function () {});
+}, 500);</script>`
+
+// injectScript adds the live-reload script to HTML responses, before </body>
+// when there is one. Responses without a body (to HEAD, or a 1xx, 204 or 304)
+// are left alone: their Content-Length, if any, describes a body not sent.
+func injectScript(resp *http.Response) error {
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") || resp.Header.Get("Content-Encoding") != "" {
+		return nil
+	}
+	if !hasBody(resp) {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+		body = append(body[:i:i], append([]byte(scriptTag), body[i:]...)...)
+	} else {
+		body = append(body, scriptTag...)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// hasBody reports whether resp may carry a body.
+func hasBody(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return false
+	}
+	switch code := resp.StatusCode; {
+	case code >= 100 && code < 200, code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func serveScript(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	io.WriteString(w, liveReloadScript)
+}
+
+// liveReloadScript reloads the page on "reload" and renders an overlay for
+// "failure". EventSource reconnects by itself when refresh restarts.
+const liveReloadScript = `(function () {
+  var overlay;
+  function hide() { if (overlay) { overlay.remove(); overlay = null; } }
+  function show(f) {
+    hide();
+    overlay = document.createElement("div");
+    overlay.id = "__refresh_overlay";
+    overlay.style.cssText = "position:fixed;inset:0;z-index:2147483647;overflow:auto;padding:2rem;" +
+      "background:rgba(24,24,27,.95);color:#fafafa;font:14px/1.5 ui-monospace,monospace;white-space:pre-wrap";
+    var title = document.createElement("div");
+    title.style.cssText = "color:#f87171;font-weight:bold;margin-bottom:1rem";
+    title.textContent = "Reload failed" + (f.process ? " in " + f.process : "");
+    overlay.appendChild(title);
+    var body = document.createElement("div");
+    body.textContent = (f.diagnostics && f.diagnostics.length ? f.diagnostics.join("\n") : f.error) || "";
+    overlay.appendChild(body);
+    document.body.appendChild(overlay);
+  }
+  var es = new EventSource("` + proxyPrefix + `events");
+  es.addEventListener("reload", function () { location.reload(); });
+  es.addEventListener("failure", function (e) { show(JSON.parse(e.data)); });
+})();
+`
@@ -0,0 +1,272 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInjectScript(t *testing.T) {
+	page := "<html><body><h1>hi</h1></body></html>"
+	tests := []struct {
+		method      string
+		status      int
+		contentType string
+		body, want  string
+	}{
+		{"GET", 200, "text/html; charset=utf-8", page, "<h1>hi</h1>" + scriptTag + "</body>"},
+		{"GET", 200, "text/html", "<p>fragment</p>", "<p>fragment</p>" + scriptTag},
+		{"GET", 200, "application/json", `{"a":1}`, `{"a":1}`},
+		{"HEAD", 200, "text/html", "", ""},
+		{"GET", 304, "text/html", "", ""},
+		{"GET", 204, "text/html", "", ""},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{
+			StatusCode: tt.status,
+			Header:     http.Header{"Content-Type": {tt.contentType}, "Content-Length": {"38"}},
+			Body:       io.NopCloser(strings.NewReader(tt.body)),
+			Request:    httptest.NewRequest(tt.method, "/", nil),
+		}
+		if err := injectScript(resp); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		if tt.want == "" {
+			if len(got) != 0 || resp.Header.Get("Content-Length") != "38" {
+				t.Errorf("%s %d: body = %q, Content-Length %s, want both untouched", tt.method, tt.status, got, resp.Header.Get("Content-Length"))
+			}
+			continue
+		}
+		if !strings.Contains(string(got), tt.want) {
+			t.Errorf("%s: body = %q, want it to contain %q", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestDialAddr(t *testing.T) {
+	tests := map[string]string{
+		"localhost:8080":        "localhost:8080",
+		"http://localhost":      "localhost:80",
+		"https://example.test/": "example.test:443",
+		"http://[::1]":          "[::1]:80",
+	}
+	for target, want := range tests {
+		u, err := proxyTarget(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := dialAddr(u); got != want {
+			t.Errorf("dialAddr(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
+
+// TestWaitingPage checks the page served while the primary is down polls the
+// ready endpoint, which reports when the primary accepts connections again.
+func TestWaitingPage(t *testing.T) {
+	addr := freeAddr(t)
+	target, err := proxyTarget(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lr := &liveReload{target: target, clients: make(map[chan string]struct{})}
+	rec := httptest.NewRecorder()
+	lr.unavailable(rec, httptest.NewRequest("GET", "/", nil), io.EOF)
+	if body := rec.Body.String(); !strings.Contains(body, proxyPrefix+"ready") || !strings.Contains(body, scriptTag) {
+		t.Errorf("waiting page = %q, want it to poll the ready endpoint and carry the script", body)
+	}
+
+	ready := func() int {
+		rec := httptest.NewRecorder()
+		lr.serveReady(rec, httptest.NewRequest("GET", proxyPrefix+"ready", nil))
+		return rec.Code
+	}
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Errorf("ready with the primary down = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if code := ready(); code != http.StatusNoContent {
+		t.Errorf("ready with the primary up = %d, want %d", code, http.StatusNoContent)
+	}
+}
+
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().String()
+}
+
+// TestLiveReloadProxy checks pages are proxied with the script injected and
+// that browsers are told to reload after a successful cycle and shown the
+// failure after a failed one.
+func TestLiveReloadProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><body>app</body></html>")
+	}))
+	defer target.Close()
+
+	dir := t.TempDir()
+	listen := freeAddr(t)
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: dir,
+		LogLevel: "mute",
+		Debounce: 100,
+		Proxy:    ProxyConfig{Listen: listen, Target: target.URL},
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "test ! -f broken", Type: Blocking},
+			{Name: "app", Cmd: "sleep 30", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go eng.Run(ctx)
+
+	base := "http://" + listen
+	var page string
+	ok := waitFor(func() bool {
+		resp, err := http.Get(base + "/")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		page = string(body)
+		return true
+	})
+	if !ok {
+		t.Fatal("proxy never answered")
+	}
+	if !strings.Contains(page, "app"+scriptTag+"</body>") {
+		t.Fatalf("page = %q, want the script injected", page)
+	}
+
+	resp, err := http.Get(base + proxyPrefix + "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := make(chan string, 8)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+	}()
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Fatalf("event = %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %q event", want)
+		}
+	}
+
+	eng.Reload()
+	expect("reload")
+	if err := os.WriteFile(filepath.Join(dir, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng.Reload()
+	expect("failure")
+}
+
+// TestLiveReloadProxyTargetDown checks a cycle waiting for its primary to
+// accept connections does not hold up a later failure, and does not reload
+// browsers over it once the primary is ready.
+func TestLiveReloadProxyTargetDown(t *testing.T) {
+	dir := t.TempDir()
+	listen, target := freeAddr(t), freeAddr(t)
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: dir,
+		LogLevel: "mute",
+		Debounce: 100,
+		Proxy:    ProxyConfig{Listen: listen, Target: target},
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "test ! -f broken", Type: Blocking},
+			{Name: "app", Cmd: "sleep 30", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var resp *http.Response
+	if !waitFor(func() bool {
+		resp, err = http.Get("http://" + listen + proxyPrefix + "events")
+		return err == nil
+	}) {
+		t.Fatal("proxy never answered")
+	}
+	defer resp.Body.Close()
+	events := make(chan string, 8)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+	}()
+
+	eng.Reload()
+	time.Sleep(500 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(dir, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng.Reload()
+	select {
+	case got := <-events:
+		if got != "failure" {
+			t.Fatalf("event = %q, want failure", got)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the failure waited for the earlier cycle's primary")
+	}
+
+	ln, err := net.Listen("tcp", target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	select {
+	case got := <-events:
+		t.Fatalf("event %q once the primary was ready, want the failure to stand", got)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// ReloadKind is the phase of a reload cycle a ReloadEvent reports.
+type ReloadKind string
+
+const (
+	// ReloadStarted is emitted when a cycle begins running its steps.
+	ReloadStarted ReloadKind = "started"
+	// ReloadSucceeded is emitted when every step of a cycle completed and the
+	// primary (if any) was restarted.
+	ReloadSucceeded ReloadKind = "succeeded"
+	// ReloadFailed is emitted when a step aborted the cycle. The previous primary
+	// keeps running.
+	ReloadFailed ReloadKind = "failed"
+	// ReloadDeferred is emitted when a reload is requested while the engine is
+	// paused; it runs on Resume.
+	ReloadDeferred ReloadKind = "deferred"
+)
+
+// ReloadTrigger is what caused a reload cycle.
+type ReloadTrigger string
+
+const (
+	// TriggerStartup is the initial pass run by Start/Run (cycle 0).
+	TriggerStartup ReloadTrigger = "startup"
+	// TriggerFileChange is a debounced change to a watched file.
+	TriggerFileChange ReloadTrigger = "file-change"
+	// TriggerReload is a call to Engine.Reload.
+	TriggerReload ReloadTrigger = "reload"
+	// TriggerResume is a reload deferred while paused and applied by Resume.
+	TriggerResume ReloadTrigger = "resume"
+)
+
+// ReloadEvent describes one phase of a reload cycle as a whole, complementing
+// the per-process ProcessEvent.
+type ReloadEvent struct {
+	Kind ReloadKind
+	// Cycle numbers reload cycles from 1; the initial startup pass is cycle 0.
+	// A deferred event carries the number the cycle will run as.
+	Cycle   int
+	Trigger ReloadTrigger
+	// Paths are the changed files (relative to the root) that led to this cycle,
+	// deduplicated and capped at the first maxReloadPaths; empty for triggers
+	// other than file changes.
+	Paths []string
+	Time  time.Time
+	// Duration is how long the cycle ran; set on succeeded and failed events.
+	Duration time.Duration
+	// Process names the step that failed the cycle; set on failed events.
+	Process string
+	// Err is the failing step's error; set on failed events.
+	Err error
+}
+
+// ReloadFunc receives reload lifecycle events. Like EventFunc it is called
+// synchronously from the supervisor goroutine and must not block.
+type ReloadFunc func(ReloadEvent)
+
+// maxReloadPaths caps the paths a reload event carries, so a change touching
+// many files (a checkout, say) does not make every event that large.
+const maxReloadPaths = 256
+
+// reloadBatch accumulates what led to a reload between the moment it is
+// requested and the moment the supervisor picks it up.
+type reloadBatch struct {
+	trigger ReloadTrigger
+	paths   []string
+	// seen holds the paths, to deduplicate them.
+	seen map[string]struct{}
+}
+
+// merge folds a later request into the batch. The first trigger wins, so a
+// batch started by a file change stays a file change even if Reload is also
+// called before it runs. Paths beyond maxReloadPaths are dropped.
+func (b *reloadBatch) merge(trigger ReloadTrigger, paths []string) {
+	if b.trigger == "" {
+		b.trigger = trigger
+	}
+	for _, p := range paths {
+		if len(b.paths) == maxReloadPaths {
+			return
+		}
+		if _, ok := b.seen[p]; ok {
+			continue
+		}
+		if b.seen == nil {
+			b.seen = make(map[string]struct{})
+		}
+		b.seen[p] = struct{}{}
+		b.paths = append(b.paths, p)
+	}
+}
+
+// requestReload records why a reload is wanted and pokes the supervisor. Safe
+// to call from any goroutine.
+func (engine *Engine) requestReload(trigger ReloadTrigger, paths []string) {
+	engine.notePending(trigger, paths)
+	nonBlockingSend(engine.reloadCh)
+}
+
+// notePending records a reload request without signalling; the watcher uses it
+// ahead of its own send on the reload channel.
+func (engine *Engine) notePending(trigger ReloadTrigger, paths []string) {
+	engine.pendingMu.Lock()
+	defer engine.pendingMu.Unlock()
+	engine.pending.merge(trigger, paths)
+}
+
+// takePending returns and clears the accumulated request. A signal with nothing
+// recorded is treated as a Reload call.
+func (engine *Engine) takePending() reloadBatch {
+	engine.pendingMu.Lock()
+	defer engine.pendingMu.Unlock()
+	batch := engine.pending
+	engine.pending = reloadBatch{}
+	if batch.trigger == "" {
+		batch.trigger = TriggerReload
+	}
+	return batch
+}
+
+// emitReload delivers a reload event to the configured hook and to subscribers.
+func (engine *Engine) emitReload(ev ReloadEvent) {
+	ev.Time = time.Now()
+	if engine.Config.OnReload != nil {
+		engine.Config.OnReload(ev)
+	}
+	engine.events.publish(EngineEvent{Kind: KindReload, Time: ev.Time, Reload: &ev})
+}
+
+// runReload runs one numbered cycle (the startup pass when first is set),
+// bracketing it with started and succeeded/failed events. Called only from the
+// supervisor goroutine.
+func (engine *Engine) runReload(ctx context.Context, batch reloadBatch, first bool) error {
+	cycle := engine.cycle
+	engine.emitReload(ReloadEvent{Kind: ReloadStarted, Cycle: cycle, Trigger: batch.trigger, Paths: batch.paths})
+	hc := HookContext{Cycle: cycle, Trigger: batch.trigger, Paths: batch.paths}
+	if !first {
+		hc.Hook = HookBeforeReload
+		engine.runHook(ctx, engine.Config.Hooks.BeforeReload, hc, true)
+	}
+	start := time.Now()
+	var err error
+	if first {
+		err = engine.ProcessManager.Start(ctx)
+	} else {
+		err = engine.ProcessManager.Reload(ctx)
+	}
+	engine.cycle++
+	done := ReloadEvent{Cycle: cycle, Trigger: batch.trigger, Paths: batch.paths, Duration: time.Since(start)}
+	if err != nil {
+		// A cycle cut short by shutdown is not a failed reload.
+		if ctx.Err() != nil {
+			return err
+		}
+		done.Kind = ReloadFailed
+		done.Err = err
+		hc.ExitCode = -1
+		var step *process.StepError
+		if errors.As(err, &step) {
+			done.Process = step.Name
+			hc.ExitCode = step.ExitCode
+		}
+		engine.emitReload(done)
+		if !first {
+			hc.Hook, hc.Process, hc.Err = HookAfterReloadFailure, done.Process, err
+			engine.runHook(ctx, engine.Config.Hooks.AfterReloadFailure, hc, false)
+		}
+		return err
+	}
+	done.Kind = ReloadSucceeded
+	engine.emitReload(done)
+	if !first {
+		hc.Hook = HookAfterReloadSuccess
+		engine.runHook(ctx, engine.Config.Hooks.AfterReloadSuccess, hc, false)
+	}
+	slog.Debug("reload cycle complete", "cycle", cycle, "duration", done.Duration)
+	return nil
+}
@@ -0,0 +1,116 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// reloadLog records ReloadEvents in a goroutine-safe slice.
+type reloadLog struct {
+	mu     sync.Mutex
+	events []ReloadEvent
+}
+
+func (l *reloadLog) record(ev ReloadEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, ev)
+}
+
+// find returns the first recorded event matching kind and cycle.
+func (l *reloadLog) find(kind ReloadKind, cycle int) (ReloadEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, ev := range l.events {
+		if ev.Kind == kind && ev.Cycle == cycle {
+			return ev, true
+		}
+	}
+	return ReloadEvent{}, false
+}
+
+// TestReloadEventsTrackCycles walks the reload lifecycle: the startup pass, a
+// programmatic reload, a reload deferred while paused and applied on resume,
+// and a failing cycle that names its failing step.
+func TestReloadEventsTrackCycles(t *testing.T) {
+	root := t.TempDir()
+	log := &reloadLog{}
+	cfg := Config{
+		RootPath: root,
+		LogLevel: "mute",
+		Debounce: 100,
+		Ignore:   Ignore{WatchedExten: []string{"*.go"}},
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "test ! -f broken", Type: Blocking},
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+		OnReload: log.record,
+	}
+	eng, err := NewEngineFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = eng.Run(ctx) }()
+
+	if !waitFor(func() bool { _, ok := log.find(ReloadSucceeded, 0); return ok }) {
+		t.Fatalf("no succeeded event for the startup pass; events = %+v", log.events)
+	}
+	if ev, _ := log.find(ReloadStarted, 0); ev.Trigger != TriggerStartup {
+		t.Errorf("startup trigger = %q, want %q", ev.Trigger, TriggerStartup)
+	}
+
+	eng.Reload()
+	if !waitFor(func() bool { _, ok := log.find(ReloadSucceeded, 1); return ok }) {
+		t.Fatalf("no succeeded event for cycle 1; events = %+v", log.events)
+	}
+	if ev, _ := log.find(ReloadSucceeded, 1); ev.Trigger != TriggerReload || ev.Duration <= 0 {
+		t.Errorf("cycle 1 = %+v, want a timed reload-triggered cycle", ev)
+	}
+
+	eng.Pause()
+	eng.Reload()
+	if !waitFor(func() bool { _, ok := log.find(ReloadDeferred, 2); return ok }) {
+		t.Fatalf("no deferred event while paused; events = %+v", log.events)
+	}
+	if err := os.WriteFile(filepath.Join(root, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	eng.Resume()
+	if !waitFor(func() bool { _, ok := log.find(ReloadFailed, 2); return ok }) {
+		t.Fatalf("no failed event for cycle 2; events = %+v", log.events)
+	}
+	failed, _ := log.find(ReloadFailed, 2)
+	if failed.Trigger != TriggerResume || failed.Process != "build" || failed.Err == nil {
+		t.Errorf("failed cycle = %+v, want a resume-triggered failure of build", failed)
+	}
+}
+
+func TestReloadBatchMergeKeepsFirstTriggerAndDedupes(t *testing.T) {
+	var b reloadBatch
+	b.merge(TriggerFileChange, []string{"a.go", "b.go"})
+	b.merge(TriggerReload, []string{"b.go", "c.go"})
+	if b.trigger != TriggerFileChange {
+		t.Errorf("trigger = %q, want %q", b.trigger, TriggerFileChange)
+	}
+	if want := []string{"a.go", "b.go", "c.go"}; !slices.Equal(b.paths, want) {
+		t.Errorf("paths = %v, want %v", b.paths, want)
+	}
+
+	var many []string
+	for i := range 2 * maxReloadPaths {
+		many = append(many, fmt.Sprintf("f%d.go", i))
+	}
+	b.merge(TriggerFileChange, many)
+	if len(b.paths) != maxReloadPaths || b.paths[3] != "f0.go" {
+		t.Errorf("kept %d paths starting %v, want the first %d", len(b.paths), b.paths[:4], maxReloadPaths)
+	}
+}
@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"errors"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// controlRequest is a lifecycle operation marshalled onto the supervisor
+// goroutine, which is the only goroutine allowed to start and stop processes.
+type controlRequest struct {
+	fn   func(ctx context.Context) error
+	done chan error
+}
+
+// errEngineStopped is returned by runtime operations issued while the engine is
+// shutting down, and by per-process lifecycle calls made while it isn't running.
+var errEngineStopped = errors.New("engine is stopped")
+
+// control runs fn on the supervisor goroutine and waits for its result. Before
+// Start/Run there is no concurrent lifecycle work, so fn runs directly on the
+// caller's goroutine instead. Once the supervisor has exited, nothing would
+// track or stop what fn starts, so it fails with errEngineStopped.
+func (engine *Engine) control(fn func(ctx context.Context) error) error {
+	if !engine.supervising.Load() {
+		if engine.stopped.Load() {
+			return errEngineStopped
+		}
+		return fn(context.Background())
+	}
+	req := controlRequest{fn: fn, done: make(chan error, 1)}
+	select {
+	case engine.controlCh <- req:
+	case <-engine.ctx.Done():
+		return errEngineStopped
+	}
+	return <-req.done
+}
+
+// AddProcess adds a process to a running (or not yet started) engine. Once the
+// engine is running, background and primary processes start immediately, a once
+// step runs now, and a blocking step joins the next reload cycle. The process
+// reports StatePending first, then its usual transitions. It is an error to add
+// a second primary or reuse a process name, or to add a process once the engine
+// has stopped. Safe to call from any goroutine; it blocks until the supervisor
+// has applied the change.
+func (engine *Engine) AddProcess(spec process.Execute) error {
+	if err := verifySpec(spec); err != nil {
+		return err
+	}
+	return engine.control(func(ctx context.Context) error {
+		if spec.Type == process.Primary && engine.hasPrimary() {
+			return errors.New("only one primary execute can be set")
+		}
+		return engine.ProcessManager.Add(ctx, spec)
+	})
+}
+
+// RemoveProcess stops the named process, waiting for it to exit, and removes
+// it from the engine; it reports StateRemoved and disappears from Processes.
+// Safe to call from any goroutine.
+func (engine *Engine) RemoveProcess(name string) error {
+	return engine.control(func(context.Context) error {
+		return engine.ProcessManager.Remove(name)
+	})
+}
+
+// UpdateProcess replaces the named process's spec, keeping its place in the
+// reload cycle and its buffered output. If it is running it is stopped and
+// restarted with the new spec; otherwise the change takes effect the next time
+// it runs. An empty spec.Name keeps the current name. Safe to call from any
+// goroutine.
+func (engine *Engine) UpdateProcess(name string, spec process.Execute) error {
+	if err := verifySpec(spec); err != nil {
+		return err
+	}
+	return engine.control(func(ctx context.Context) error {
+		if spec.Type == process.Primary {
+			for _, info := range engine.ProcessManager.Snapshot() {
+				if info.Type == process.Primary && info.Name != name {
+					return errors.New("only one primary execute can be set")
+				}
+			}
+		}
+		return engine.ProcessManager.Update(ctx, name, spec)
+	})
+}
+
+// hasPrimary reports whether a primary process is currently configured.
+func (engine *Engine) hasPrimary() bool {
+	for _, info := range engine.ProcessManager.Snapshot() {
+		if info.Type == process.Primary {
+			return true
+		}
+	}
+	return false
+}
+
+// RestartProcess restarts just the named process, through the supervisor so it
+// never races a reload cycle. Background and primary processes are stopped and
+// started again; once and blocking steps are re-run to completion, returning
+// their error. It errors when the engine is not running. Safe to call from any
+// goroutine.
+func (engine *Engine) RestartProcess(name string) error {
+	if !engine.supervising.Load() {
+		return errEngineStopped
+	}
+	return engine.control(func(ctx context.Context) error {
+		return engine.ProcessManager.RestartProcess(ctx, name)
+	})
+}
+
+// StopProcess stops just the named process and waits for it to exit; it then
+// reports StateKilled in Processes. A stopped background process stays down
+// until StartProcess; a stopped primary comes back with the next reload cycle.
+// It errors when the engine is not running. Safe to call from any goroutine.
+func (engine *Engine) StopProcess(name string) error {
+	if !engine.supervising.Load() {
+		return errEngineStopped
+	}
+	return engine.control(func(context.Context) error {
+		return engine.ProcessManager.StopProcess(name)
+	})
+}
+
+// StartProcess starts just the named process: a background or primary process
+// that is not running is started, and a once or blocking step is run to
+// completion. Starting a process that is already running, or calling this while
+// the engine is not running, is an error. Safe to call from any goroutine.
+func (engine *Engine) StartProcess(name string) error {
+	if !engine.supervising.Load() {
+		return errEngineStopped
+	}
+	return engine.control(func(ctx context.Context) error {
+		return engine.ProcessManager.StartProcess(ctx, name)
+	})
+}
@@ -0,0 +1,243 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// processInfo returns the named process's snapshot entry.
+func processInfo(eng *Engine, name string) (ProcessInfo, bool) {
+	for _, info := range eng.Processes() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return ProcessInfo{}, false
+}
+
+// TestRuntimeAddUpdateRemove drives the runtime process API against a running
+// engine: a process added live starts, an update restarts it with the new
+// command, and removing it kills it and drops it from the snapshot.
+func TestRuntimeAddUpdateRemove(t *testing.T) {
+	cfg := Config{
+		RootPath:   t.TempDir(),
+		LogLevel:   "mute",
+		Debounce:   100,
+		Ignore:     Ignore{WatchedExten: []string{"*.go"}},
+		ExecStruct: []Execute{{Name: "server", Cmd: "sleep 30", Type: Primary}},
+	}
+	eng, err := NewEngineFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = eng.Run(ctx) }()
+	if !waitFor(func() bool { return serverPID(eng, "server") > 0 }) {
+		t.Fatal("server never started")
+	}
+
+	if err := eng.AddProcess(Execute{Name: "queue", Cmd: "sleep 31", Type: Background}); err != nil {
+		t.Fatalf("AddProcess: %v", err)
+	}
+	if !waitFor(func() bool { return serverPID(eng, "queue") > 0 }) {
+		t.Fatalf("added background process never started; snapshot = %+v", eng.Processes())
+	}
+	if err := eng.AddProcess(Execute{Name: "queue", Cmd: "true", Type: Once}); err == nil {
+		t.Error("expected an error adding a duplicate name")
+	}
+	if err := eng.AddProcess(Execute{Name: "other", Cmd: "sleep 30", Type: Primary}); err == nil {
+		t.Error("expected an error adding a second primary")
+	}
+
+	oldPID := serverPID(eng, "queue")
+	if err := eng.UpdateProcess("queue", Execute{Cmd: "sleep 32", Type: Background}); err != nil {
+		t.Fatalf("UpdateProcess: %v", err)
+	}
+	info, ok := processInfo(eng, "queue")
+	if !ok || info.Exec != "sleep 32" || info.PID == 0 || info.PID == oldPID {
+		t.Errorf("updated process = %+v, want sleep 32 running under a new pid", info)
+	}
+	if !waitFor(func() bool { return !pidAlive(oldPID) }) {
+		t.Errorf("old instance (pid %d) survived the update", oldPID)
+	}
+
+	pid := info.PID
+	if err := eng.RemoveProcess("queue"); err != nil {
+		t.Fatalf("RemoveProcess: %v", err)
+	}
+	if _, ok := processInfo(eng, "queue"); ok {
+		t.Error("removed process still in snapshot")
+	}
+	if !waitFor(func() bool { return !pidAlive(pid) }) {
+		t.Errorf("removed process (pid %d) still alive", pid)
+	}
+	if err := eng.RemoveProcess("queue"); err == nil {
+		t.Error("expected an error removing an unknown process")
+	}
+}
+
+func TestAddProcessBeforeStartIsPending(t *testing.T) {
+	eng, err := NewEngineFromConfig(Config{
+		RootPath:   ".",
+		LogLevel:   "mute",
+		ExecStruct: []Execute{{Name: "server", Cmd: "./app", Type: Primary}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.AddProcess(Execute{Name: "gen", Cmd: "true", Type: Blocking}); err != nil {
+		t.Fatalf("AddProcess: %v", err)
+	}
+	info, ok := processInfo(eng, "gen")
+	if !ok || info.State != StatePending {
+		t.Errorf("process added before start = %+v, want pending", info)
+	}
+}
+
+// TestPerProcessLifecycle verifies StopProcess, StartProcess and RestartProcess
+// act on one process only and are reflected in Processes.
+func TestPerProcessLifecycle(t *testing.T) {
+	cfg := Config{
+		RootPath: t.TempDir(),
+		LogLevel: "mute",
+		Debounce: 100,
+		Ignore:   Ignore{WatchedExten: []string{"*.go"}},
+		ExecStruct: []Execute{
+			{Name: "queue", Cmd: "sleep 30", Type: Background},
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+	}
+	eng, err := NewEngineFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+	if err := eng.StopProcess("queue"); err == nil {
+		t.Error("expected StopProcess to fail before the engine runs")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = eng.Run(ctx) }()
+	if !waitFor(func() bool { return serverPID(eng, "queue") > 0 && serverPID(eng, "server") > 0 }) {
+		t.Fatal("processes never started")
+	}
+	queuePID, serverPIDBefore := serverPID(eng, "queue"), serverPID(eng, "server")
+
+	if err := eng.StopProcess("queue"); err != nil {
+		t.Fatalf("StopProcess: %v", err)
+	}
+	if info, _ := processInfo(eng, "queue"); info.State != StateKilled || pidAlive(queuePID) {
+		t.Errorf("queue after stop = %+v (pid %d alive=%v), want killed", info, queuePID, pidAlive(queuePID))
+	}
+	if err := eng.StartProcess("queue"); err != nil {
+		t.Fatalf("StartProcess: %v", err)
+	}
+	if err := eng.StartProcess("queue"); err == nil {
+		t.Error("expected an error starting an already running process")
+	}
+
+	restarted := serverPID(eng, "queue")
+	if err := eng.RestartProcess("queue"); err != nil {
+		t.Fatalf("RestartProcess: %v", err)
+	}
+	if pid := serverPID(eng, "queue"); pid == 0 || pid == restarted {
+		t.Errorf("queue pid after restart = %d, want a new pid (was %d)", pid, restarted)
+	}
+	if pid := serverPID(eng, "server"); pid != serverPIDBefore {
+		t.Errorf("server pid changed from %d to %d; lifecycle calls must only touch their process", serverPIDBefore, pid)
+	}
+	if err := eng.RestartProcess("nope"); err == nil {
+		t.Error("expected an error for an unknown process")
+	}
+}
+
+// TestStartProcessAfterExit checks a process that exited on its own, rather
+// than being stopped, can be started again.
+func TestStartProcessAfterExit(t *testing.T) {
+	root := t.TempDir()
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: root,
+		LogLevel: "mute",
+		Debounce: 100,
+		ExecStruct: []Execute{
+			{Name: "worker", Cmd: "echo run >> runs; exit 3", Type: Background},
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	runsDone := func(n int) func() bool {
+		return func() bool {
+			data, _ := os.ReadFile(filepath.Join(root, "runs"))
+			info, _ := processInfo(eng, "worker")
+			return strings.Count(string(data), "run") == n && info.State == StateFailed
+		}
+	}
+	if !waitFor(runsDone(1)) {
+		t.Fatal("worker never ran and failed")
+	}
+	if err := eng.StartProcess("worker"); err != nil {
+		t.Fatalf("StartProcess after the worker failed: %v", err)
+	}
+	if !waitFor(runsDone(2)) {
+		t.Error("worker did not run again")
+	}
+}
+
+// TestRuntimeCallsAfterShutdown checks runtime operations fail once Run has
+// returned instead of starting processes nothing would stop.
+func TestRuntimeCallsAfterShutdown(t *testing.T) {
+	root := t.TempDir()
+	eng, err := NewEngineFromConfig(Config{
+		RootPath: root,
+		LogLevel: "mute",
+		ExecStruct: []Execute{
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- eng.Run(ctx) }()
+	if !waitFor(func() bool { return serverPID(eng, "server") != 0 }) {
+		t.Fatal("server never started")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	late := Execute{Name: "late", Cmd: "echo started > late", Type: Background}
+	if err := eng.AddProcess(late); !errors.Is(err, errEngineStopped) {
+		t.Errorf("AddProcess after shutdown = %v, want %v", err, errEngineStopped)
+	}
+	if err := eng.UpdateProcess("server", Execute{Cmd: "echo started > late", Type: Primary}); !errors.Is(err, errEngineStopped) {
+		t.Errorf("UpdateProcess after shutdown = %v, want %v", err, errEngineStopped)
+	}
+	if err := eng.RemoveProcess("server"); !errors.Is(err, errEngineStopped) {
+		t.Errorf("RemoveProcess after shutdown = %v, want %v", err, errEngineStopped)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(filepath.Join(root, "late")); err == nil {
+		t.Error("a process started after shutdown")
+	}
+}
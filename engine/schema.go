@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// SchemaID is the $id of the generated config schema.
+const SchemaID = "https://github.com/atterpac/refresh/refresh.schema.json"
+
+// schemaDescriptions documents config keys in the generated schema, keyed by
+// "<struct>.<key>"; editors show them on hover and in completions.
+var schemaDescriptions = map[string]string{
+	"Config.root_path":        "Directory to watch and run commands in.",
+	"Config.background":       "A command started once at startup that survives reloads and is stopped on shutdown.",
+	"Config.ignore":           "Which changes are ignored by the watcher.",
+	"Config.executes":         "Commands run on startup and on every reload, in order.",
+	"Config.exec_list":        "Shorthand for executes as plain command strings; REFRESH marks the next command as the primary process.",
+	"Config.log_level":        "Engine log verbosity.",
+	"Config.debounce":         "Milliseconds of quiet after a change before reloading.",
+	"Config.enable_pause":     "Use Ctrl+Z to pause and resume reloads instead of suspending refresh.",
+	"Config.log_buffer":       "Lines of each process's stdout and stderr kept in memory; 0 disables buffering.",
+	"Config.metrics_interval": "Milliseconds between samples of each running process's CPU and memory use (Linux only); 0 disables sampling.",
+	"Config.strict":           "Refuse to load this file if validation finds errors, such as unknown keys.",
+	"Config.proxy":            "Live-reload reverse proxy in front of the primary: browsers reload after a successful reload and show an overlay when it fails.",
+	"Config.exit_on":          "Stop refresh when the primary exits on its own: never (default), primary-exit on any exit, primary-failure on a non-zero status. refresh then exits with the primary's status.",
+	"Config.once_primary":     "Make refresh --once also run the primary process until it exits, instead of skipping it.",
+	"Config.extends":          "A config file this one is layered on, relative to this file. Keys set here override it; executes and profiles are merged by name.",
+	"Config.profile":          "The profile applied when REFRESH_PROFILE is unset.",
+	"Config.shell":            "Shell that runs each execute's cmd unless it sets its own, e.g. \"bash -lc\"; empty uses /bin/sh -c (cmd /C on Windows).",
+	"Config.profiles":         "Named overrides of this config, selected with profile, --profile or REFRESH_PROFILE.",
+	"Config.hooks":            "Commands run around reloads, primary crashes and shutdown, with REFRESH_HOOK, REFRESH_CYCLE, REFRESH_TRIGGER, REFRESH_CHANGED_FILES, REFRESH_PROCESS, REFRESH_EXIT_CODE and REFRESH_ERROR in their environment.",
+
+	"Hooks.on_change":            "Runs when a watched file change requests a reload, also while paused.",
+	"Hooks.before_reload":        "Runs before each reload cycle; the cycle waits for it.",
+	"Hooks.after_reload_success": "Runs after a reload cycle completes.",
+	"Hooks.after_reload_failure": "Runs after a step fails a reload cycle; REFRESH_PROCESS names the step.",
+	"Hooks.on_primary_crash":     "Runs when the primary exits on its own with a non-zero status or from a signal.",
+	"Hooks.on_shutdown":          "Runs once refresh has stopped its processes; shutdown waits for it.",
+
+	"Profile.root_path":    "Replaces root_path.",
+	"Profile.log_level":    "Replaces log_level.",
+	"Profile.debounce":     "Replaces debounce.",
+	"Profile.enable_pause": "Replaces enable_pause.",
+	"Profile.log_buffer":   "Replaces log_buffer.",
+	"Profile.ignore":       "Appended to the base ignore lists; git = true also enables .gitignore.",
+	"Profile.background":   "Replaces the background command.",
+	"Profile.executes":     "Replace the base execute with the same name in place, or are appended.",
+	"Profile.remove":       "Names of base executes to drop.",
+
+	"ProxyConfig.listen": "Address the proxy serves on, e.g. \":3000\".",
+	"ProxyConfig.target": "Address of the primary, e.g. \"localhost:8080\" or \"http://localhost:8080\".",
+
+	"Ignore.dir":               "Directories (or patterns) whose changes are ignored.",
+	"Ignore.file":              "Files (or patterns) whose changes are ignored.",
+	"Ignore.watched_extension": "Only changes to matching files trigger a reload, e.g. \"*.go\"; empty watches every file.",
+	"Ignore.git":               "Also ignore paths listed in the root .gitignore.",
+
+	"Execute.name":             "Stable identifier for the process; defaults to the command.",
+	"Execute.cmd":              "Command to run through the shell.",
+	"Execute.args":             "The command as an argv list, run directly without a shell instead of cmd; the program is looked up on PATH.",
+	"Execute.shell":            "Shell that runs cmd, e.g. \"bash -lc\" or \"zsh\"; overrides the top-level shell.",
+	"Execute.dir":              "Directory to run the command in, relative to root_path.",
+	"Execute.delay_next":       "Milliseconds to wait after this step before starting the next.",
+	"Execute.type":             "background: started once and kept running; once: run once at startup; blocking: run to completion every cycle; primary: the long-running process restarted on reload; periodic: run to completion on its schedule.",
+	"Execute.schedule":         "When a periodic task runs: an interval such as \"10m\", first run at startup, or a cron expression such as \"0 * * * *\" or @hourly, @daily, @weekly, @monthly.",
+	"Execute.diagnostics":      "Parsers run over a failed step's output: go, govet, tsc, or regex:<pattern>.",
+	"Execute.restart_strategy": "How a primary is replaced on reload: stop-first (default) stops the old instance first; start-first starts the new one and stops the old one only once the new one is ready.",
+	"Execute.ready":            "Readiness check of a start-first restart: a host:port that must accept TCP connections, or an http(s) URL that must answer below 400. Empty waits for the new instance to stay up for a second.",
+	"Execute.ready_timeout":    "Milliseconds the readiness check may take before the new instance is abandoned; 0 means 10000.",
+	"Execute.ports":            "TCP ports the primary binds; a new instance starts only once each is free again.",
+	"Execute.port_timeout":     "Milliseconds to wait for ports to be released before the step fails; 0 means 5000.",
+	"Execute.inputs":           "Globs of the files a blocking or once step reads, relative to its dir (** matches directories). The step is skipped while they and the command are unchanged since its last successful run.",
+	"Execute.outputs":          "Globs of the files an incremental step produces; it re-runs when they changed or went missing.",
+	"Execute.listen":           "TCP addresses refresh binds once and hands to every new instance as inherited sockets (LISTEN_FDS), so connections queue across restarts. Primary and background only; Unix only.",
+	"Execute.limits":           "Resource limits of the process and everything it starts. Unix only; ionice and cgroup are Linux only.",
+
+	"Limits.memory_mb":   "Maximum memory of the process and its children in megabytes; exceeding it kills them. With cgroup, the group's memory.max; on macOS only the data segment (RLIMIT_DATA).",
+	"Limits.cpu_seconds": "Maximum CPU time in seconds (RLIMIT_CPU); the process is killed with SIGXCPU when it uses more.",
+	"Limits.open_files":  "Maximum number of open file descriptors (RLIMIT_NOFILE).",
+	"Limits.nice":        "Scheduling priority, from -20 (highest) to 19 (lowest).",
+	"Limits.ionice":      "I/O scheduling class: idle, best-effort or realtime, optionally followed by :<level> from 0 to 7. Linux only.",
+	"Limits.cgroup":      "Run each instance in its own cgroup v2 group when possible, enforcing memory_mb for the whole group and reporting when it is exceeded. Linux only.",
+}
+
+// schemaEnums restricts config keys to a fixed set of values.
+var schemaEnums = map[string][]string{
+	"Config.log_level":  {"debug", "info", "warn", "error", "mute"},
+	"Profile.log_level": {"debug", "info", "warn", "error", "mute"},
+	"Config.exit_on": {
+		string(ExitNever), string(ExitOnPrimaryExit), string(ExitOnPrimaryFailure),
+	},
+	"Execute.type": {
+		string(process.Background), string(process.Once),
+		string(process.Blocking), string(process.Primary),
+		string(process.Periodic),
+	},
+	"Execute.restart_strategy": {string(process.StopFirst), string(process.StartFirst)},
+}
+
+// JSONSchema returns a JSON Schema (draft-07) describing refresh config files,
+// generated from the Config, Ignore and Execute structs. Unknown keys are
+// rejected, so editors flag typos. The same document validates TOML and YAML
+// configs through editor plugins that apply JSON Schemas to them.
+func JSONSchema() ([]byte, error) {
+	schema := map[string]any{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"$id":         SchemaID,
+		"title":       "refresh config",
+		"type":        "object",
+		"description": "Configuration for the refresh hot-reload engine.",
+		"properties": map[string]any{
+			"$schema": map[string]any{"type": "string"},
+			"config":  schemaFor(reflect.TypeFor[Config]()),
+		},
+		"additionalProperties": false,
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(schema); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// schemaFor maps a Go type to its schema, following the json keys of structs.
+// Fields that can only be set from code (functions, loggers) are skipped.
+func schemaFor(t reflect.Type) map[string]any {
+	// Types decoded from text, such as hooks, are written as strings.
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]any)
+		for i := range t.NumField() {
+			f := t.Field(i)
+			key := jsonKey(f)
+			if key == "" {
+				continue
+			}
+			prop := schemaFor(f.Type)
+			if prop == nil {
+				continue
+			}
+			id := t.Name() + "." + key
+			if desc, ok := schemaDescriptions[id]; ok {
+				prop["description"] = desc
+			}
+			if enum, ok := schemaEnums[id]; ok {
+				prop["enum"] = enum
+			}
+			props[key] = prop
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		values := schemaFor(t.Elem())
+		if values == nil || t.Key().Kind() != reflect.String {
+			return nil
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Slice:
+		items := schemaFor(t.Elem())
+		if items == nil {
+			return nil
+		}
+		return map[string]any{"type": "array", "items": items}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return nil
+	}
+}
+
+// jsonKey is the key a struct field is decoded from, or "" when it is not part
+// of the file format.
+func jsonKey(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" || name == "" {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// TestJSONSchemaDescribesConfig checks the generated schema follows the file
+// format: every config key is present, code-only fields are left out, unknown
+// keys are rejected, and execute types are enumerated.
+func TestJSONSchemaDescribesConfig(t *testing.T) {
+	raw, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	type node struct {
+		Type                 string           `json:"type"`
+		Properties           map[string]*node `json:"properties"`
+		Items                *node            `json:"items"`
+		Enum                 []string         `json:"enum"`
+		AdditionalProperties json.RawMessage  `json:"additionalProperties"`
+	}
+	var root node
+	if err := json.Unmarshal(raw, &root); err != nil {
+		t.Fatalf("schema is not valid JSON: %v", err)
+	}
+	config := root.Properties["config"]
+	if config == nil {
+		t.Fatal("schema has no config object")
+	}
+	for _, key := range []string{"root_path", "background", "ignore", "executes", "exec_list", "log_level", "debounce", "enable_pause", "log_buffer"} {
+		if config.Properties[key] == nil {
+			t.Errorf("config.%s missing from schema", key)
+		}
+	}
+	for _, key := range []string{"Callback", "Slog", "Output", "OnProcessEvent", "OnReload"} {
+		if config.Properties[key] != nil {
+			t.Errorf("code-only field %s leaked into the schema", key)
+		}
+	}
+	if string(config.AdditionalProperties) != "false" {
+		t.Error("config does not reject unknown keys")
+	}
+
+	exec := config.Properties["executes"].Items
+	if exec == nil || exec.Properties["delay_next"] == nil || exec.Properties["delay_next"].Type != "integer" {
+		t.Fatalf("executes items = %+v, want delay_next as an integer", exec)
+	}
+	if exec.Properties["Parsers"] != nil || exec.Properties["parsers"] != nil {
+		t.Error("code-only Parsers leaked into the schema")
+	}
+	if got := exec.Properties["type"].Enum; !slices.Equal(got, []string{"background", "once", "blocking", "primary", "periodic"}) {
+		t.Errorf("execute type enum = %v", got)
+	}
+	var profile node
+	if err := json.Unmarshal(config.Properties["profiles"].AdditionalProperties, &profile); err != nil || profile.Properties["executes"] == nil {
+		t.Errorf("profiles values = %s, want profile objects", config.Properties["profiles"].AdditionalProperties)
+	}
+	if config.Properties["ignore"].Properties["watched_extension"].Items.Type != "string" {
+		t.Error("ignore.watched_extension is not a list of strings")
+	}
+}
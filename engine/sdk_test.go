@@ -344,3 +344,39 @@ func TestCancelDuringStartupKillsStartedProcesses(t *testing.T) {
 		t.Errorf("background pid %d survived shutdown — orphaned", bgPID)
 	}
 }
+
+// TestLogsRetainRecentOutput verifies Config.LogBuffer keeps a bounded tail of
+// each process's output, queryable by name through Engine.Logs.
+func TestLogsRetainRecentOutput(t *testing.T) {
+	cfg := Config{
+		RootPath:  t.TempDir(),
+		LogLevel:  "mute",
+		Debounce:  100,
+		LogBuffer: 2,
+		Ignore:    Ignore{WatchedExten: []string{"*.go"}},
+		ExecStruct: []Execute{
+			{Name: "banner", Cmd: "echo a; echo b; echo c", Type: Blocking},
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+		// Keep the captured output off the test's terminal.
+		Output: func(ProcessInfo, string) io.Writer { return io.Discard },
+	}
+	eng, err := NewEngineFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = eng.Run(ctx) }()
+
+	if !waitFor(func() bool { return len(eng.Logs("banner", 0)) == 2 }) {
+		t.Fatalf("Logs(banner) = %+v, want the last 2 lines", eng.Logs("banner", 0))
+	}
+	if got := eng.Logs("banner", 0); got[0].Text != "b" || got[1].Text != "c" {
+		t.Errorf("Logs(banner) = %+v, want [b c]", got)
+	}
+	if got := eng.Logs("nope", 10); got != nil {
+		t.Errorf("Logs(nope) = %+v, want nil", got)
+	}
+}
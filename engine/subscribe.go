@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// EventKind identifies what an EngineEvent carries.
+type EventKind string
+
+const (
+	// KindProcess events carry a ProcessEvent (a single process changed state).
+	KindProcess EventKind = "process"
+	// KindReload events carry a ReloadEvent (a reload cycle changed phase).
+	KindReload EventKind = "reload"
+	// KindMetrics events carry a process's snapshot with a new Metrics sample
+	// (see Config.MetricsInterval).
+	KindMetrics EventKind = "metrics"
+)
+
+// EngineEvent is one entry in a Subscribe stream. Exactly one payload field is
+// set, matching Kind.
+type EngineEvent struct {
+	Kind EventKind
+	Time time.Time
+	// Process is set for KindProcess events.
+	Process *process.ProcessEvent
+	// Reload is set for KindReload events.
+	Reload *ReloadEvent
+	// Metrics is set for KindMetrics events.
+	Metrics *process.ProcessInfo
+	// Dropped is how many of this subscriber's events were discarded to make
+	// room for this one (see Subscribe); summed over the stream it is the total
+	// lost.
+	Dropped int
+}
+
+// EventFilter narrows a subscription. The zero value receives everything.
+type EventFilter struct {
+	// Names limits process-scoped events (process state and metrics) to these
+	// process names. Engine-wide events (reload cycles) are not tied to a
+	// process and always pass.
+	Names []string
+	// Kinds limits the stream to these kinds of event.
+	Kinds []EventKind
+}
+
+func (f EventFilter) match(ev EngineEvent) bool {
+	if len(f.Kinds) > 0 && !slices.Contains(f.Kinds, ev.Kind) {
+		return false
+	}
+	if name := ev.processName(); name != "" && len(f.Names) > 0 && !slices.Contains(f.Names, name) {
+		return false
+	}
+	return true
+}
+
+// processName is the process an event concerns, or "" for engine-wide events.
+func (ev EngineEvent) processName() string {
+	if ev.Process != nil {
+		return ev.Process.Info.Name
+	}
+	if ev.Metrics != nil {
+		return ev.Metrics.Name
+	}
+	return ""
+}
+
+// SubscriberBuffer is the number of events buffered per subscriber before the
+// overflow policy applies.
+const SubscriberBuffer = 256
+
+// subscriber is one Subscribe stream. dropped counts events discarded and not
+// yet reported on a delivered event; guarded by eventHub.mu.
+type subscriber struct {
+	ch      chan EngineEvent
+	filter  EventFilter
+	dropped int
+}
+
+// eventHub fans engine events out to every subscriber. Publishing never blocks:
+// it holds mu only for non-blocking channel operations, and unsubscribing closes
+// the channel under the same lock, so a publish can never hit a closed channel.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+// Subscribe returns a stream of engine events matching filter until ctx is
+// cancelled, at which point the channel is closed. Any number of subscribers
+// may be active at once, and subscribing is safe from any goroutine, before or
+// after the engine starts.
+//
+// Delivery is buffered (SubscriberBuffer events) and never blocks the engine.
+// When a subscriber's buffer is full, its oldest undelivered event is discarded
+// to make room for the newest, so a slow consumer always catches up to the
+// current state; the event that displaced it reports the loss in Dropped.
+func (engine *Engine) Subscribe(ctx context.Context, filter EventFilter) <-chan EngineEvent {
+	sub := &subscriber{ch: make(chan EngineEvent, SubscriberBuffer), filter: filter}
+	engine.events.mu.Lock()
+	if engine.events.subs == nil {
+		engine.events.subs = make(map[*subscriber]struct{})
+	}
+	engine.events.subs[sub] = struct{}{}
+	engine.events.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		engine.events.mu.Lock()
+		delete(engine.events.subs, sub)
+		close(sub.ch)
+		engine.events.mu.Unlock()
+	}()
+	return sub.ch
+}
+
+// publish delivers an event to every matching subscriber.
+func (h *eventHub) publish(ev EngineEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.filter.match(ev) {
+			continue
+		}
+		out := ev
+		out.Dropped = sub.dropped
+		select {
+		case sub.ch <- out:
+			sub.dropped = 0
+			continue
+		default:
+		}
+		// Full: discard the oldest buffered event, then retry once. The consumer
+		// may have drained a slot meanwhile, in which case nothing is discarded.
+		// The discarded event's own Dropped count carries over, so none is lost.
+		select {
+		case old := <-sub.ch:
+			sub.dropped += 1 + old.Dropped
+			out.Dropped = sub.dropped
+		default:
+		}
+		select {
+		case sub.ch <- out:
+			sub.dropped = 0
+		default:
+			sub.dropped++
+		}
+	}
+}
+
+// onProcessEvent is the process manager's event hook: it forwards to the
+// configured OnProcessEvent and publishes to subscribers.
+func (engine *Engine) onProcessEvent(ev process.ProcessEvent) {
+	if engine.Config.OnProcessEvent != nil {
+		engine.Config.OnProcessEvent(ev)
+	}
+	engine.events.publish(EngineEvent{Kind: KindProcess, Time: ev.Time, Process: &ev})
+	engine.notePrimaryExit(ev)
+}
+
+// onMetrics is the process manager's metrics hook: it forwards to the
+// configured OnMetrics and publishes to subscribers.
+func (engine *Engine) onMetrics(info process.ProcessInfo) {
+	if engine.Config.OnMetrics != nil {
+		engine.Config.OnMetrics(info)
+	}
+	engine.events.publish(EngineEvent{Kind: KindMetrics, Time: info.Metrics.SampledAt, Metrics: &info})
+}
@@ -0,0 +1,122 @@
+//go:build linux || darwin
+
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/atterpac/refresh/process"
+)
+
+// TestSubscribeFansOutWithFilters verifies multiple concurrent subscribers each
+// receive the events their filter selects, and that cancelling a subscription
+// closes its channel.
+func TestSubscribeFansOutWithFilters(t *testing.T) {
+	cfg := Config{
+		RootPath: t.TempDir(),
+		LogLevel: "mute",
+		Debounce: 100,
+		Ignore:   Ignore{WatchedExten: []string{"*.go"}},
+		ExecStruct: []Execute{
+			{Name: "build", Cmd: "true", Type: Blocking},
+			{Name: "server", Cmd: "sleep 30", Type: Primary},
+		},
+	}
+	eng, err := NewEngineFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewEngineFromConfig: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	all := eng.Subscribe(ctx, EventFilter{})
+	serverOnly := eng.Subscribe(ctx, EventFilter{Names: []string{"server"}, Kinds: []EventKind{KindProcess}})
+	go func() { _ = eng.Run(ctx) }()
+
+	var sawReload, sawBuild bool
+	deadline := time.After(3 * time.Second)
+	for !sawReload || !sawBuild {
+		select {
+		case ev := <-all:
+			sawReload = sawReload || (ev.Kind == KindReload && ev.Reload.Kind == ReloadSucceeded)
+			sawBuild = sawBuild || (ev.Kind == KindProcess && ev.Process.Info.Name == "build")
+		case <-deadline:
+			t.Fatalf("unfiltered subscriber missed events: reload=%v build=%v", sawReload, sawBuild)
+		}
+	}
+
+	select {
+	case ev := <-serverOnly:
+		if ev.Kind != KindProcess || ev.Process.Info.Name != "server" {
+			t.Errorf("filtered subscriber got %+v, want only server process events", ev)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("filtered subscriber received nothing")
+	}
+
+	subCtx, subCancel := context.WithCancel(context.Background())
+	sub := eng.Subscribe(subCtx, EventFilter{})
+	subCancel()
+	if !waitFor(func() bool {
+		select {
+		case _, ok := <-sub:
+			return !ok
+		default:
+			return false
+		}
+	}) {
+		t.Error("channel not closed after the subscription's context was cancelled")
+	}
+}
+
+// TestSubscribeOverflowDropsOldest verifies a full subscriber loses its oldest
+// events, never blocks the publisher, and learns how many it missed — also
+// when the events it lost themselves carried a count, as happens once more
+// than a buffer's worth overflows.
+func TestSubscribeOverflowDropsOldest(t *testing.T) {
+	eng := &Engine{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub := eng.Subscribe(ctx, EventFilter{})
+
+	const published = 3*SubscriberBuffer + 3
+	for i := range published {
+		eng.events.publish(EngineEvent{Kind: KindReload, Reload: &ReloadEvent{Cycle: i}})
+	}
+
+	lost := published - SubscriberBuffer
+	first := <-sub
+	if first.Reload.Cycle != lost {
+		t.Errorf("oldest retained cycle = %d, want %d (the oldest dropped)", first.Reload.Cycle, lost)
+	}
+	last, dropped := first, first.Dropped
+	for range SubscriberBuffer - 1 {
+		last = <-sub
+		dropped += last.Dropped
+	}
+	if last.Reload.Cycle != published-1 || dropped != lost {
+		t.Errorf("newest event = cycle %d, total dropped %d; want cycle %d, %d dropped", last.Reload.Cycle, dropped, published-1, lost)
+	}
+}
+
+func TestEventFilterMatch(t *testing.T) {
+	proc := EngineEvent{Kind: KindProcess, Process: &process.ProcessEvent{Info: process.ProcessInfo{Name: "api"}}}
+	reload := EngineEvent{Kind: KindReload, Reload: &ReloadEvent{}}
+	metrics := EngineEvent{Kind: KindMetrics, Metrics: &process.ProcessInfo{Name: "api"}}
+
+	byName := EventFilter{Names: []string{"web"}}
+	if byName.match(proc) {
+		t.Error("name filter matched another process's event")
+	}
+	if byName.match(metrics) {
+		t.Error("name filter matched another process's metrics")
+	}
+	if !byName.match(reload) {
+		t.Error("name filter rejected an engine-wide reload event")
+	}
+	if (EventFilter{Kinds: []EventKind{KindProcess}}).match(reload) {
+		t.Error("kind filter matched an excluded kind")
+	}
+}
@@ -0,0 +1,529 @@
+package engine
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/atterpac/refresh/process"
+	"gopkg.in/yaml.v2"
+)
+
+// IssueSeverity grades a ConfigIssue.
+type IssueSeverity string
+
+const (
+	// SeverityError marks a config that is wrong: refresh would misbehave or
+	// silently ignore part of it.
+	SeverityError IssueSeverity = "error"
+	// SeverityWarning marks something likely wrong that may still work, such as
+	// a step directory created by an earlier step.
+	SeverityWarning IssueSeverity = "warning"
+)
+
+// ConfigIssue is one problem found in a config file.
+type ConfigIssue struct {
+	File string
+	// Line and Column locate the offending key, 1-based; zero when the problem
+	// concerns the file as a whole.
+	Line     int
+	Column   int
+	Severity IssueSeverity
+	// Key is the dotted path of the offending key, e.g. config.executes[1].type.
+	Key     string
+	Message string
+}
+
+// String formats the issue as file:line:col: severity: key: message, the form
+// editors and CI annotate.
+func (i ConfigIssue) String() string {
+	var b strings.Builder
+	b.WriteString(i.File)
+	if i.Line > 0 {
+		fmt.Fprintf(&b, ":%d", i.Line)
+		if i.Column > 0 {
+			fmt.Fprintf(&b, ":%d", i.Column)
+		}
+	}
+	fmt.Fprintf(&b, ": %s: ", i.Severity)
+	if i.Key != "" {
+		b.WriteString(i.Key + ": ")
+	}
+	b.WriteString(i.Message)
+	return b.String()
+}
+
+// ConfigError is returned when a strict config fails validation. It carries
+// every issue, not just the first.
+type ConfigError struct {
+	Issues []ConfigIssue
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		lines[i] = issue.String()
+	}
+	return "invalid config:\n" + strings.Join(lines, "\n")
+}
+
+// ValidateConfigFile checks a TOML, YAML or JSON config file strictly and
+// returns every issue found, or nil when it is clean. Beyond what loading a
+// config verifies, it reports keys refresh does not recognise (which loading
+// silently drops), invalid execute types, step directories that do not exist,
+// duplicate process names, negative delays, and watched extensions that can
+// never match. The error is non-nil only when the file cannot be read.
+func ValidateConfigFile(path string) ([]ConfigIssue, error) {
+	return ValidateConfigFileWith(path, Overrides{})
+}
+
+// ValidateConfigFileWith is ValidateConfigFile for the config as loaded with
+// overrides: their profile is the one checked and their values replace the
+// file's.
+func ValidateConfigFileWith(path string, overrides Overrides) ([]ConfigIssue, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{file: path, data: data, engine: &Engine{}, overrides: overrides}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		v.keys = locateYAML(data)
+		v.decodeYAML()
+	case ".json":
+		v.keys = locateJSON(data)
+		v.decodeJSON()
+	default:
+		v.keys = locateTOML(data)
+		v.decodeTOML()
+	}
+	if v.decoded {
+		v.checkLoaded(path)
+	}
+	slices.SortStableFunc(v.issues, func(a, b ConfigIssue) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return v.issues, nil
+}
+
+// hasErrors reports whether any issue is an error rather than a warning.
+func hasErrors(issues []ConfigIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStrict is the load-time check for configs that set strict: the file
+// must validate without errors.
+func validateStrict(path string, overrides Overrides) error {
+	issues, err := ValidateConfigFileWith(path, overrides)
+	if err != nil {
+		return err
+	}
+	if hasErrors(issues) {
+		return &ConfigError{Issues: issues}
+	}
+	return nil
+}
+
+// checkLoaded runs the semantic checks over the config as the engine would
+// run it: layered on the file it extends, with the selected profile applied and
+// variables expanded.
+func (v *validator) checkLoaded(path string) {
+	dir := configDir(path)
+	full := &Engine{overrides: v.overrides}
+	if err := full.decodeLayers(v.data, decoderFor(path), dir, []string{filepath.Join(dir, filepath.Base(path))}); err != nil {
+		v.add(SeverityError, []string{"config", "extends"}, "%v", err)
+		return
+	}
+	if err := full.applyProfile(); err != nil {
+		v.add(SeverityError, []string{"config", "profile"}, "%v", err)
+		return
+	}
+	if err := full.Config.ApplyOverrides(v.overrides); err != nil {
+		v.add(SeverityError, []string{"config"}, "%v", err)
+		return
+	}
+	full.expandConfig(dir)
+	v.check(full.Config)
+}
+
+// validator accumulates the issues of one file.
+type validator struct {
+	file    string
+	data    []byte
+	keys    keyIndex
+	engine  *Engine
+	decoded bool
+	// overrides are applied to the loaded config before it is checked.
+	overrides Overrides
+	issues    []ConfigIssue
+}
+
+func (v *validator) add(severity IssueSeverity, path []string, format string, args ...any) {
+	line, col := v.keys.find(path)
+	v.addAt(severity, line, col, formatPath(path), fmt.Sprintf(format, args...))
+}
+
+func (v *validator) addAt(severity IssueSeverity, line, col int, key, message string) {
+	v.issues = append(v.issues, ConfigIssue{
+		File: v.file, Line: line, Column: col, Severity: severity, Key: key, Message: message,
+	})
+}
+
+func (v *validator) decodeTOML() {
+	md, err := toml.Decode(string(v.data), v.engine)
+	if err != nil {
+		var perr toml.ParseError
+		if errors.As(err, &perr) {
+			v.addAt(SeverityError, perr.Position.Line, perr.Position.Col, "", perr.Message)
+			return
+		}
+		// Type mismatches are reported as "line N (last key "k"): message".
+		if m := tomlTypeError.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			kp, _ := v.keys.at(line, "")
+			v.addAt(SeverityError, line, kp.col, m[2], m[3])
+			return
+		}
+		v.addAt(SeverityError, 0, 0, "", err.Error())
+		return
+	}
+	v.decoded = true
+	undecoded := md.Undecoded()
+	unknown := make(map[string]bool, len(undecoded))
+	for _, key := range undecoded {
+		unknown[key.String()] = true
+	}
+	for _, key := range undecoded {
+		// Keys under an unknown table are reported once, at the table.
+		if len(key) > 1 && unknown[key[:len(key)-1].String()] {
+			continue
+		}
+		positions := v.keys.matching(key)
+		if len(positions) == 0 {
+			v.addAt(SeverityError, 0, 0, key.String(), "unknown key")
+		}
+		for _, kp := range positions {
+			v.addAt(SeverityError, kp.line, kp.col, formatPath(kp.path), "unknown key")
+		}
+	}
+}
+
+var tomlTypeError = regexp.MustCompile(`line (\d+) \(last key "([^"]*)"\): (.*)$`)
+
+var (
+	yamlErrorLine  = regexp.MustCompile(`^line (\d+): (.*)$`)
+	yamlUnknownKey = regexp.MustCompile(`^field (\S+) not found in type`)
+	yamlSyntaxLine = regexp.MustCompile(`line (\d+)`)
+)
+
+func (v *validator) decodeYAML() {
+	err := yaml.UnmarshalStrict(v.data, v.engine)
+	var terr *yaml.TypeError
+	if err != nil && !errors.As(err, &terr) {
+		// A syntax error: nothing was decoded.
+		line := 0
+		if m := yamlSyntaxLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		v.addAt(SeverityError, line, 0, "", strings.TrimPrefix(err.Error(), "yaml: "))
+		return
+	}
+	v.decoded = true
+	if terr == nil {
+		return
+	}
+	for _, msg := range terr.Errors {
+		m := yamlErrorLine.FindStringSubmatch(msg)
+		if m == nil {
+			v.addAt(SeverityError, 0, 0, "", msg)
+			continue
+		}
+		line, _ := strconv.Atoi(m[1])
+		if u := yamlUnknownKey.FindStringSubmatch(m[2]); u != nil {
+			if kp, ok := v.keys.at(line, u[1]); ok {
+				v.addAt(SeverityError, kp.line, kp.col, formatPath(kp.path), "unknown key")
+				continue
+			}
+			v.addAt(SeverityError, line, 0, u[1], "unknown key")
+			continue
+		}
+		kp, ok := v.keys.at(line, "")
+		if !ok {
+			v.addAt(SeverityError, line, 0, "", m[2])
+			continue
+		}
+		v.addAt(SeverityError, line, kp.col, formatPath(kp.path), m[2])
+	}
+}
+
+func (v *validator) decodeJSON() {
+	if err := json.Unmarshal(v.data, v.engine); err != nil {
+		var serr *json.SyntaxError
+		var terr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &serr):
+			line, col := offsetPosition(v.data, int(serr.Offset))
+			v.addAt(SeverityError, line, col, "", serr.Error())
+		case errors.As(err, &terr):
+			line, col := offsetPosition(v.data, int(terr.Offset))
+			v.addAt(SeverityError, line, col, terr.Field, fmt.Sprintf("expected %s, got %s", terr.Type, terr.Value))
+		default:
+			v.addAt(SeverityError, 0, 0, "", err.Error())
+		}
+		return
+	}
+	v.decoded = true
+	// encoding/json stops at the first unknown field, so walk the document
+	// against the config structs to report every one.
+	var doc any
+	if err := json.Unmarshal(v.data, &doc); err != nil {
+		return
+	}
+	v.unknownJSON(doc, reflect.TypeFor[Engine](), nil)
+}
+
+// unknownJSON reports object keys in doc with no matching json field in t.
+func (v *validator) unknownJSON(doc any, t reflect.Type, path []string) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		v.unknownJSON(doc, t.Elem(), path)
+	case reflect.Slice:
+		list, _ := doc.([]any)
+		for i, item := range list {
+			v.unknownJSON(item, t.Elem(), append(slices.Clone(path), strconv.Itoa(i)))
+		}
+	case reflect.Map:
+		obj, _ := doc.(map[string]any)
+		for key, value := range obj {
+			v.unknownJSON(value, t.Elem(), append(slices.Clone(path), key))
+		}
+	case reflect.Struct:
+		obj, _ := doc.(map[string]any)
+		for key, value := range obj {
+			keyPath := append(slices.Clone(path), key)
+			field, ok := jsonField(t, key)
+			if !ok {
+				if len(path) == 0 && key == "$schema" {
+					continue
+				}
+				v.add(SeverityError, keyPath, "unknown key")
+				continue
+			}
+			v.unknownJSON(value, field.Type, keyPath)
+		}
+	}
+}
+
+// jsonField finds the field of t that the json key decodes into, matching
+// case-insensitively as encoding/json does.
+func jsonField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if name := jsonKey(f); name != "" && strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// check runs the semantic checks over a decoded config.
+func (v *validator) check(cfg Config) {
+	root := []string{"config"}
+	at := func(keys ...string) []string { return append(slices.Clone(root), keys...) }
+
+	if cfg.RootPath == "" {
+		v.add(SeverityError, at("root_path"), "root path is required")
+	}
+	if cfg.Debounce < 0 {
+		v.add(SeverityError, at("debounce"), "must not be negative (got %d)", cfg.Debounce)
+	}
+	if cfg.LogBuffer < 0 {
+		v.add(SeverityError, at("log_buffer"), "must not be negative (got %d)", cfg.LogBuffer)
+	}
+	if cfg.MetricsInterval < 0 {
+		v.add(SeverityError, at("metrics_interval"), "must not be negative (got %d)", cfg.MetricsInterval)
+	}
+	if cfg.Proxy.Listen != "" {
+		if _, err := proxyTarget(cfg.Proxy.Target); err != nil {
+			v.add(SeverityError, at("proxy", "target"), "%v", err)
+		}
+	}
+	if !cfg.ExitOn.valid() {
+		v.add(SeverityError, at("exit_on"), "invalid exit policy %q (want never, primary-exit or primary-failure)", cfg.ExitOn)
+	}
+
+	type step struct {
+		spec process.Execute
+		path []string
+	}
+	var steps []step
+	if cfg.BackgroundStruct.Command() != "" {
+		bg := cfg.BackgroundStruct
+		bg.Type = process.Background
+		steps = append(steps, step{bg, at("background")})
+	}
+	switch {
+	case len(cfg.ExecStruct) > 0:
+		for i, exe := range cfg.ExecStruct {
+			steps = append(steps, step{exe, at("executes", strconv.Itoa(i))})
+		}
+	case len(cfg.ExecList) > 0:
+		for _, exe := range execListToSpecs(cfg.ExecList) {
+			steps = append(steps, step{exe, at("exec_list")})
+		}
+	default:
+		v.add(SeverityError, root, "at least one execute must be provided via executes or exec_list")
+	}
+
+	names := make(map[string]bool)
+	primaries := 0
+	for _, s := range steps {
+		spec := s.spec
+		key := func(keys ...string) []string { return append(slices.Clone(s.path), keys...) }
+		// Markers carry no command of their own; only their position matters.
+		if spec.Cmd == process.KILL_EXEC || spec.Cmd == process.REFRESH_EXEC {
+			continue
+		}
+		switch {
+		case spec.Cmd == "" && len(spec.Args) == 0:
+			v.add(SeverityError, s.path, "cmd or args is required")
+		case spec.Cmd != "" && len(spec.Args) > 0:
+			v.add(SeverityError, key("args"), "cmd and args are mutually exclusive")
+		case len(spec.Args) > 0 && spec.Shell != "":
+			v.add(SeverityWarning, key("shell"), "args run without a shell; shell is ignored")
+		}
+		switch spec.Type {
+		case process.Background, process.Once, process.Blocking:
+		case process.Periodic:
+			if _, err := process.ParseSchedule(spec.Schedule); err != nil {
+				v.add(SeverityError, key("schedule"), "%v", err)
+			}
+		case process.Primary:
+			primaries++
+			if primaries > 1 {
+				v.add(SeverityError, key("type"), "only one primary execute can be set")
+			}
+		case "":
+			v.add(SeverityError, s.path, "type is required (background, once, blocking, primary or periodic)")
+		default:
+			v.add(SeverityError, key("type"), "invalid execute type %q (want background, once, blocking, primary or periodic)", spec.Type)
+		}
+		if spec.Schedule != "" && spec.Type != process.Periodic {
+			v.add(SeverityWarning, key("schedule"), "only periodic executes run on a schedule; ignored for %s", spec.Type)
+		}
+		if name := specName(spec); names[name] {
+			v.add(SeverityError, key(nameKey(spec)), "duplicate process name %q", name)
+		} else {
+			names[name] = true
+		}
+		if len(spec.Listen) > 0 && spec.Type != process.Primary && spec.Type != process.Background {
+			v.add(SeverityWarning, key("listen"), "only primary and background executes are handed sockets; ignored for %s", spec.Type)
+		}
+		switch spec.RestartStrategy {
+		case "", process.StopFirst, process.StartFirst:
+		default:
+			v.add(SeverityError, key("restart_strategy"), "invalid restart strategy %q (want stop-first or start-first)", spec.RestartStrategy)
+		}
+		if spec.RestartStrategy != "" && spec.Type != process.Primary {
+			v.add(SeverityWarning, key("restart_strategy"), "only applies to the primary; ignored for %s", spec.Type)
+		}
+		if spec.Ready != "" && spec.RestartStrategy != process.StartFirst {
+			v.add(SeverityWarning, key("ready"), "only used by restart_strategy = \"start-first\"")
+		}
+		if spec.ReadyTimeout < 0 {
+			v.add(SeverityError, key("ready_timeout"), "must not be negative (got %d)", spec.ReadyTimeout)
+		}
+		for i, port := range spec.Ports {
+			if port < 1 || port > 65535 {
+				v.add(SeverityError, key("ports", strconv.Itoa(i)), "port %d is out of range", port)
+			}
+		}
+		if port := listenedPort(spec); port != 0 {
+			v.add(SeverityError, key("ports"), "port %d is also in listen, whose socket refresh holds, so waiting for it to be free always times out", port)
+		}
+		if len(spec.Ports) > 0 && spec.Type != process.Primary {
+			v.add(SeverityWarning, key("ports"), "only the primary waits for its ports; ignored for %s", spec.Type)
+		}
+		if spec.PortTimeout < 0 {
+			v.add(SeverityError, key("port_timeout"), "must not be negative (got %d)", spec.PortTimeout)
+		}
+		if len(spec.Inputs) > 0 && spec.Type != process.Blocking && spec.Type != process.Once {
+			v.add(SeverityWarning, key("inputs"), "only blocking and once steps are skipped on unchanged inputs; ignored for %s", spec.Type)
+		}
+		if len(spec.Outputs) > 0 && len(spec.Inputs) == 0 {
+			v.add(SeverityWarning, key("outputs"), "only used together with inputs")
+		}
+		if err := spec.Limits.Validate(); err != nil {
+			v.add(SeverityError, key("limits"), "%v", err)
+		} else if spec.Limits.MemoryMB > 0 && runtime.GOOS != "linux" {
+			v.add(SeverityWarning, key("limits", "memory_mb"), "only caps the data segment (RLIMIT_DATA) on %s, which does not bound runtimes that allocate with mmap, such as Go", runtime.GOOS)
+		}
+		if spec.DelayNext < 0 {
+			v.add(SeverityError, key("delay_next"), "must not be negative (got %d)", spec.DelayNext)
+		}
+		for i, name := range spec.Diagnostics {
+			if _, err := process.LookupParser(name); err != nil {
+				v.add(SeverityError, key("diagnostics", strconv.Itoa(i)), "%v", err)
+			}
+		}
+		if spec.ChangeDir != "" && cfg.RootPath != "" {
+			dir := spec.ChangeDir
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(cfg.RootPath, dir)
+			}
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				v.add(SeverityWarning, key("dir"), "directory %s does not exist", dir)
+			}
+		}
+	}
+
+	for i, pattern := range cfg.Ignore.WatchedExten {
+		if reason := unreachableExtension(pattern, cfg.Ignore); reason != "" {
+			v.add(SeverityWarning, at("ignore", "watched_extension", strconv.Itoa(i)), "%q %s", pattern, reason)
+		}
+	}
+}
+
+// nameKey is the key a process takes its name from.
+func nameKey(spec process.Execute) string {
+	if spec.Name == "" {
+		return "cmd"
+	}
+	return "name"
+}
+
+// unreachableExtension explains why a watched_extension entry can never let a
+// change through, or returns "". Entries are compared against the file's
+// extension (".go", "*.go") or matched as a pattern against its full path, and
+// files without an extension are never watched.
+func unreachableExtension(pattern string, ignore Ignore) string {
+	ext := filepath.Ext(pattern)
+	if ext == "" && !strings.HasSuffix(pattern, "*") {
+		return "matches only files without an extension, which are never watched"
+	}
+	if !strings.HasPrefix(pattern, ".") && !strings.HasPrefix(pattern, "*") && !filepath.IsAbs(pattern) {
+		return `never matches: patterns are matched against the full path, so start it with "*" (e.g. "*` + ext + `")`
+	}
+	if pattern == ext || pattern == "*"+ext {
+		probe := filepath.Join(string(filepath.Separator), "refresh-probe"+ext)
+		if patternMatch(probe, ignore.File) {
+			return "never matches: every such file is ignored by ignore.file"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// issueAt finds the issue reported for key.
+func issueAt(issues []ConfigIssue, key string) (ConfigIssue, bool) {
+	for _, issue := range issues {
+		if issue.Key == key {
+			return issue, true
+		}
+	}
+	return ConfigIssue{}, false
+}
+
+type wantIssue struct {
+	key       string
+	line, col int
+	severity  IssueSeverity
+}
+
+func checkIssues(t *testing.T, issues []ConfigIssue, want []wantIssue) {
+	t.Helper()
+	for _, w := range want {
+		issue, ok := issueAt(issues, w.key)
+		if !ok {
+			t.Errorf("no issue for %s; got:\n%v", w.key, issues)
+			continue
+		}
+		if issue.Line != w.line || issue.Column != w.col || issue.Severity != w.severity {
+			t.Errorf("%s reported at %d:%d (%s), want %d:%d (%s)", w.key,
+				issue.Line, issue.Column, issue.Severity, w.line, w.col, w.severity)
+		}
+	}
+	if len(issues) != len(want) {
+		t.Errorf("got %d issues, want %d:\n%v", len(issues), len(want), issues)
+	}
+}
+
+func TestValidateTOML(t *testing.T) {
+	path := writeConfig(t, "refresh.toml", `[config]
+root_path = "."
+debounce = -5
+colour = true
+
+[config.ignore]
+watched_extension = ["*.go", "go.mod"]
+
+[[config.executes]]
+name = "build"
+cmd = "go build"
+type = "blocking"
+delay_nxt = 100
+
+[[config.executes]]
+name = "build"
+cmd = "./app"
+type = "primray"
+dir = "./missing"
+delay_next = -1
+`)
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIssues(t, issues, []wantIssue{
+		{"config.debounce", 3, 1, SeverityError},
+		{"config.colour", 4, 1, SeverityError},
+		{"config.ignore.watched_extension[1]", 7, 1, SeverityWarning},
+		{"config.executes[0].delay_nxt", 13, 1, SeverityError},
+		{"config.executes[1].name", 16, 1, SeverityError},
+		{"config.executes[1].type", 18, 1, SeverityError},
+		{"config.executes[1].dir", 19, 1, SeverityWarning},
+		{"config.executes[1].delay_next", 20, 1, SeverityError},
+	})
+}
+
+func TestValidateYAML(t *testing.T) {
+	path := writeConfig(t, "refresh.yaml", `config:
+  root_path: "."
+  colour: true
+  ignore:
+    watched_extension:
+      - "*.go"
+      - Makefile
+  executes:
+  - name: build
+    cmd: go build
+    type: blocking
+    delay_nxt: 100
+  - cmd: ./app
+    type: primray
+`)
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIssues(t, issues, []wantIssue{
+		{"config.colour", 3, 3, SeverityError},
+		{"config.ignore.watched_extension[1]", 7, 7, SeverityWarning},
+		{"config.executes[0].delay_nxt", 12, 5, SeverityError},
+		{"config.executes[1].type", 14, 5, SeverityError},
+	})
+}
+
+func TestValidateJSON(t *testing.T) {
+	path := writeConfig(t, "refresh.json", `{
+  "$schema": "./refresh.schema.json",
+  "config": {
+    "root_path": ".",
+    "colour": true,
+    "executes": [
+      {"name": "app", "cmd": "./app", "type": "primary", "delay_nxt": 1},
+      {"name": "app", "cmd": "./app", "type": "primary"}
+    ]
+  }
+}`)
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIssues(t, issues, []wantIssue{
+		{"config.colour", 5, 5, SeverityError},
+		{"config.executes[0].delay_nxt", 7, 58, SeverityError},
+		{"config.executes[1].name", 8, 8, SeverityError},
+		{"config.executes[1].type", 8, 39, SeverityError},
+	})
+}
+
+func TestValidateReportsDecodeErrors(t *testing.T) {
+	path := writeConfig(t, "refresh.toml", "[config]\nroot_path = \".\"\ndebounce = \"soon\"\n")
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Line != 3 || issues[0].Key != "config.debounce" {
+		t.Errorf("issues = %v, want one type error at line 3 for config.debounce", issues)
+	}
+}
+
+// TestValidateRejectsListenedPort checks a port refresh holds through listen
+// cannot also be waited for through ports.
+func TestValidateRejectsListenedPort(t *testing.T) {
+	path := writeConfig(t, "refresh.toml", `[config]
+root_path = "."
+
+[[config.executes]]
+cmd = "./app"
+type = "primary"
+listen = [":8080"]
+ports = [8080]
+`)
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkIssues(t, issues, []wantIssue{
+		{"config.executes[0].ports", 8, 1, SeverityError},
+	})
+}
+
+func TestValidateCleanConfig(t *testing.T) {
+	path := writeConfig(t, "refresh.toml", tomlConfig)
+	issues, err := ValidateConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("clean config reported issues: %v", issues)
+	}
+}
+
+// TestStrictConfigRefusesUnknownKeys checks strict = true turns validation
+// errors into a load failure that lists them, while the same file loads
+// leniently without it.
+func TestStrictConfigRefusesUnknownKeys(t *testing.T) {
+	config := `[config]
+root_path = "."
+log_level = "mute"
+%s
+[[config.executes]]
+cmd = "./app"
+type = "primary"
+delay_nxt = 100
+`
+	if _, err := NewEngineFromTOML(writeConfig(t, "refresh.toml", strings.Replace(config, "%s", "", 1))); err != nil {
+		t.Fatalf("lenient load failed: %v", err)
+	}
+	_, err := NewEngineFromTOML(writeConfig(t, "refresh.toml", strings.Replace(config, "%s", "strict = true", 1)))
+	var cerr *ConfigError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("strict load error = %v, want a *ConfigError", err)
+	}
+	if len(cerr.Issues) != 1 || cerr.Issues[0].Key != "config.executes[0].delay_nxt" || cerr.Issues[0].Line != 8 {
+		t.Errorf("issues = %v, want the unknown delay_nxt at line 8", cerr.Issues)
+	}
+}
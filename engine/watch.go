@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/rjeczalik/notify"
@@ -16,12 +17,14 @@ import (
 // of writes (editors often emit several per save) collapses into one reload
 // fired after the quiet interval — true trailing-edge debounce.
 type watcher struct {
-	engine   *Engine
-	events   chan notify.EventInfo
-	reload   chan<- struct{}
-	debounce time.Duration
-	root     string
-	timer    *time.Timer
+	engine *Engine
+	events chan notify.EventInfo
+	reload chan<- struct{}
+	root   string
+	timer  *time.Timer
+	// paths collects the relative paths of the changes in the current debounce
+	// window, handed to the engine with the reload they trigger.
+	paths []string
 }
 
 // startWatcher begins watching the resolved root directory and spawns the
@@ -41,12 +44,12 @@ func (engine *Engine) startWatcher(ctx context.Context, reload chan<- struct{})
 		return fmt.Errorf("starting file watcher: %w", err)
 	}
 
+	engine.storeWatchRules()
 	w := &watcher{
-		engine:   engine,
-		events:   events,
-		reload:   reload,
-		debounce: time.Duration(engine.Config.Debounce) * time.Millisecond,
-		root:     root,
+		engine: engine,
+		events: events,
+		reload: reload,
+		root:   root,
 	}
 	go w.run(ctx)
 	slog.Info("watching for changes", "root", root)
@@ -101,24 +104,52 @@ func (w *watcher) handle(ei notify.EventInfo) {
 		}
 	}
 
-	if w.engine.Config.Ignore.shouldIgnore(ei.Path()) {
+	// The config files are reloaded by their own watcher; editing them is not a
+	// source change.
+	if files := w.engine.configFiles.Load(); files != nil && slices.Contains(*files, ei.Path()) {
+		return
+	}
+
+	rules := w.engine.rules.Load()
+	if rules.ignore.shouldIgnore(ei.Path()) {
 		slog.Debug("ignoring change", "path", rel)
 		return
 	}
 
 	slog.Debug("change detected", "path", rel, "event", info.Name)
-	w.timer.Reset(w.debounce)
+	w.paths = append(w.paths, rel)
+	w.timer.Reset(rules.debounce)
 }
 
-// signalReload performs a non-blocking send so a reload that is already queued
-// is not duplicated; the buffered channel coalesces bursts into one reload.
+// signalReload records the window's changed paths with the engine, then
+// performs a non-blocking send so a reload that is already queued is not
+// duplicated; the buffered channel coalesces bursts into one reload.
 func (w *watcher) signalReload() {
+	w.engine.notePending(TriggerFileChange, w.paths)
+	w.paths = nil
 	select {
 	case w.reload <- struct{}{}:
 	default:
 	}
 }
 
+// watchRules are the parts of the config the watcher applies to every event.
+// They are replaced as a whole when the config file is reloaded, so the watcher
+// goroutine always sees a consistent set.
+type watchRules struct {
+	ignore   Ignore
+	debounce time.Duration
+}
+
+// storeWatchRules publishes the current ignore rules and debounce to the
+// watcher.
+func (engine *Engine) storeWatchRules() {
+	engine.rules.Store(&watchRules{
+		ignore:   engine.Config.Ignore,
+		debounce: time.Duration(engine.Config.Debounce) * time.Millisecond,
+	})
+}
+
 func (w *watcher) relPath(path string) string {
 	rel, err := filepath.Rel(w.root, path)
 	if err != nil {
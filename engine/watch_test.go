@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"testing"
 	"time"
@@ -139,3 +140,26 @@ func TestWatcherIgnoresUnwatchedExtensions(t *testing.T) {
 		t.Errorf("unwatched extension triggered %d reloads, want 0", got)
 	}
 }
+
+func TestWatcherRecordsChangedPaths(t *testing.T) {
+	root := t.TempDir()
+	e := newWatchTestEngine(t, root, 100)
+
+	reload := make(chan struct{}, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := e.startWatcher(ctx, reload); err != nil {
+		t.Fatalf("startWatcher: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	batch := e.takePending()
+	if batch.trigger != TriggerFileChange || !slices.Contains(batch.paths, "a.txt") {
+		t.Errorf("pending reload = %+v, want a file change carrying a.txt", batch)
+	}
+}
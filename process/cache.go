@@ -0,0 +1,246 @@
+package process
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CacheDir is the directory, under the root, where refresh keeps state that
+// survives restarts, such as the fingerprints of incremental steps. The
+// watcher ignores changes inside it.
+const CacheDir = ".refresh"
+
+const stepCacheFile = "steps.json"
+
+// stepFingerprint identifies the inputs and outputs of an incremental step's
+// last successful run.
+type stepFingerprint struct {
+	Inputs  string `json:"inputs"`
+	Outputs string `json:"outputs,omitempty"`
+}
+
+// stepCache maps step names to the fingerprint of their last successful run,
+// persisted as JSON.
+type stepCache struct {
+	path    string
+	entries map[string]stepFingerprint
+}
+
+// stepCache returns the cache of the root directory, loading it on first use.
+// A missing or unreadable cache file starts an empty cache.
+func (pm *ProcessManager) stepCache() *stepCache {
+	if pm.cache != nil {
+		return pm.cache
+	}
+	pm.cache = &stepCache{
+		path:    filepath.Join(pm.RootDir, CacheDir, stepCacheFile),
+		entries: make(map[string]stepFingerprint),
+	}
+	if data, err := os.ReadFile(pm.cache.path); err == nil {
+		if err := json.Unmarshal(data, &pm.cache.entries); err != nil {
+			slog.Debug("discarding unreadable step cache", "path", pm.cache.path, "err", err)
+			pm.cache.entries = make(map[string]stepFingerprint)
+		}
+	}
+	return pm.cache
+}
+
+// set records or, for a zero fingerprint, forgets a step and saves the cache.
+// Failing to save only costs a re-run after a restart, so it is logged.
+func (c *stepCache) set(name string, fp stepFingerprint) {
+	if fp == (stepFingerprint{}) {
+		if _, ok := c.entries[name]; !ok {
+			return
+		}
+		delete(c.entries, name)
+	} else {
+		c.entries[name] = fp
+	}
+	if err := c.save(); err != nil {
+		slog.Warn("saving step cache", "path", c.path, "err", err)
+	}
+}
+
+func (c *stepCache) save() error {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// Keep the cache out of version control.
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); errors.Is(err, fs.ErrNotExist) {
+		_ = os.WriteFile(ignore, []byte("*\n"), 0o644)
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// runStep runs a blocking or once step to completion, unless it declares
+// Inputs whose fingerprint, and that of its Outputs, match its last successful
+// run: it is then reported as StateSkipped instead. A step whose files cannot
+// be hashed just runs.
+func (pm *ProcessManager) runStep(ctx context.Context, p *Process) error {
+	if len(p.spec.Inputs) == 0 {
+		return pm.runBlocking(ctx, p)
+	}
+	cache := pm.stepCache()
+	name := p.displayName()
+	inputs, err := pm.fingerprint(p, p.spec.Inputs)
+	if err != nil {
+		slog.Warn("hashing step inputs", "exec", p.Exec, "err", err)
+		return pm.runBlocking(ctx, p)
+	}
+	if last, ok := cache.entries[name]; ok && last.Inputs == inputs {
+		if outputs, err := pm.fingerprint(p, p.spec.Outputs); err == nil && outputs == last.Outputs {
+			slog.Debug("inputs unchanged, skipping step", "exec", p.Exec)
+			pm.transition(p, StateSkipped, 0, keepExitCode, nil)
+			return nil
+		}
+	}
+
+	if err := pm.runBlocking(ctx, p); err != nil {
+		cache.set(name, stepFingerprint{})
+		return err
+	}
+	outputs, err := pm.fingerprint(p, p.spec.Outputs)
+	if err != nil {
+		slog.Warn("hashing step outputs", "exec", p.Exec, "err", err)
+		cache.set(name, stepFingerprint{})
+		return nil
+	}
+	cache.set(name, stepFingerprint{Inputs: inputs, Outputs: outputs})
+	return nil
+}
+
+// fingerprint hashes the command of p together with the paths and contents of
+// the files matching patterns in its directory. It is empty when there are no
+// patterns.
+func (pm *ProcessManager) fingerprint(p *Process, patterns []string) (string, error) {
+	if len(patterns) == 0 {
+		return "", nil
+	}
+	dir := pm.resolveDir(p.Dir)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00", p.Exec, p.spec.Shell, dir)
+	for _, pattern := range patterns {
+		files, err := globFiles(dir, pattern)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", pattern, len(files))
+		for _, file := range files {
+			rel, err := filepath.Rel(dir, file)
+			if err != nil {
+				rel = file
+			}
+			fmt.Fprintf(h, "%s\x00", filepath.ToSlash(rel))
+			if err := hashFile(h, file); err != nil {
+				return "", err
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(h hash.Hash, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(h, f)
+	return err
+}
+
+// globFiles returns the regular files matching pattern, a glob relative to dir
+// in which ** matches any number of directories, in lexical order. The .git
+// and CacheDir directories are not searched.
+func globFiles(dir, pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	if !path.IsAbs(pattern) && !filepath.IsAbs(pattern) {
+		pattern = path.Join(filepath.ToSlash(dir), pattern)
+	}
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("glob %q: %w", pattern, err)
+	}
+	// Walk from the deepest directory without wildcards.
+	base := pattern
+	for strings.ContainsAny(base, "*?[") {
+		base = path.Dir(base)
+	}
+	var files []string
+	err = filepath.WalkDir(filepath.FromSlash(base), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			if n := d.Name(); n == ".git" || n == CacheDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() && re.MatchString(filepath.ToSlash(name)) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// globRegexp translates a slash-separated glob into an anchored regexp: *
+// and ? stay within a path element, [...] is a character class, and **
+// crosses directories.
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[' && strings.IndexByte(pattern[i:], ']') > 1:
+			end := i + strings.IndexByte(pattern[i:], ']')
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
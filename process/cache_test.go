@@ -0,0 +1,111 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIncrementalStep checks a step with inputs is skipped while its inputs and
+// outputs are unchanged, also after a restart, and re-runs when either changes.
+func TestIncrementalStep(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("proto/api/v1/service.proto", "service A {}")
+	write("proto/README.md", "not an input")
+
+	var pm *ProcessManager
+	start := func() {
+		t.Helper()
+		pm = NewProcessManager()
+		if err := pm.SetRootDirectory(root); err != nil {
+			t.Fatal(err)
+		}
+		err := pm.AddProcessSpec(Execute{
+			Name:    "protoc",
+			Cmd:     "cat proto/api/v1/*.proto > gen.txt; echo run >> runs",
+			Type:    Blocking,
+			Inputs:  []string{"proto/**/*.proto"},
+			Outputs: []string{"gen.txt"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := pm.Start(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs := func() int {
+		data, _ := os.ReadFile(filepath.Join(root, "runs"))
+		return strings.Count(string(data), "run")
+	}
+	reload := func(wantRuns int, wantState ProcessState) {
+		t.Helper()
+		if err := pm.Reload(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := runs(); got != wantRuns {
+			t.Errorf("runs = %d, want %d", got, wantRuns)
+		}
+		if state := pm.Snapshot()[0].State; state != wantState {
+			t.Errorf("state = %s, want %s", state, wantState)
+		}
+	}
+
+	start()
+	if runs() != 1 {
+		t.Fatalf("runs after start = %d, want 1", runs())
+	}
+	reload(1, StateSkipped)
+	write("proto/README.md", "still not an input")
+	reload(1, StateSkipped)
+	write("proto/api/v1/service.proto", "service B {}")
+	reload(2, StateExited)
+
+	// The cache survives a restart.
+	start()
+	if runs() != 2 || pm.Snapshot()[0].State != StateSkipped {
+		t.Errorf("after restart: runs %d, state %s; want the step skipped", runs(), pm.Snapshot()[0].State)
+	}
+	if err := os.Remove(filepath.Join(root, "gen.txt")); err != nil {
+		t.Fatal(err)
+	}
+	reload(3, StateExited)
+}
+
+func TestGlobRegexp(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**", "src/a/b", true},
+		{"file?.[ch]", "file1.c", true},
+		{"file?.[!ch]", "file1.c", false},
+		{"a+b(c).txt", "a+b(c).txt", true},
+	} {
+		re, err := globRegexp(tc.pattern)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.pattern, err)
+		}
+		if got := re.MatchString(tc.path); got != tc.want {
+			t.Errorf("%s matching %s = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+// TestCgroupStartWithoutClone3 checks an instance clone3 cannot start inside
+// its group is started again without it and moved into the group.
+func TestCgroupStartWithoutClone3(t *testing.T) {
+	t.Cleanup(func() { cloneIntoCgroupUnavailable.Store(false) })
+	cg := &cgroup{dir: t.TempDir()}
+	cmd := exec.Command("true")
+	if err := cg.attach(cmd); err != nil {
+		t.Fatal(err)
+	}
+	var attempts int
+	err := cg.start(cmd, func(cmd *exec.Cmd) error {
+		attempts++
+		if cmd.SysProcAttr.UseCgroupFD {
+			return &os.PathError{Op: "fork/exec", Path: cmd.Path, Err: syscall.ENOSYS}
+		}
+		return cmd.Start()
+	})
+	if err != nil {
+		t.Fatalf("start = %v, want the retry to start the process", err)
+	}
+	defer cmd.Wait()
+	if attempts != 2 {
+		t.Errorf("%d start attempts, want 2", attempts)
+	}
+	if err := cg.started(cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(cg.dir, "cgroup.procs"))
+	if err != nil || string(data) != strconv.Itoa(cmd.Process.Pid) {
+		t.Errorf("cgroup.procs = %q (%v), want the pid", data, err)
+	}
+	next := &cgroup{dir: t.TempDir()}
+	if err := next.attach(exec.Command("true")); err != nil || !next.late {
+		t.Errorf("attach = %v, want later instances to join once started", err)
+	}
+}
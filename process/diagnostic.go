@@ -0,0 +1,185 @@
+package process
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Diagnostic is one structured problem (typically a compiler error) parsed from
+// the output of a failed blocking or once step.
+type Diagnostic struct {
+	// Process is the name of the step whose output produced the diagnostic.
+	Process string
+	// Parser is the name of the parser that recognized the line (e.g. "go").
+	Parser string
+	File   string
+	// Line and Column are 1-based; zero when the tool did not report them.
+	Line   int
+	Column int
+	// Severity is "error" or "warning" when the tool reports one; empty otherwise.
+	Severity string
+	// Code is a tool-specific identifier such as "TS2322"; empty when absent.
+	Code    string
+	Message string
+}
+
+// String renders the diagnostic in the conventional file:line:col: message form.
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	b.WriteString(d.File)
+	if d.Line > 0 {
+		fmt.Fprintf(&b, ":%d", d.Line)
+		if d.Column > 0 {
+			fmt.Fprintf(&b, ":%d", d.Column)
+		}
+	}
+	b.WriteString(": ")
+	if d.Severity != "" {
+		b.WriteString(d.Severity + " ")
+	}
+	if d.Code != "" {
+		b.WriteString(d.Code + ": ")
+	}
+	b.WriteString(d.Message)
+	return b.String()
+}
+
+// DiagnosticParser turns a failed step's captured output into diagnostics. An
+// SDK user may attach their own implementation through Execute.Parsers.
+type DiagnosticParser interface {
+	// Name identifies the parser in Diagnostic.Parser.
+	Name() string
+	// Parse returns every diagnostic found in output; nil when none match.
+	Parse(output string) []Diagnostic
+}
+
+// regexParser matches output line by line against one or more patterns whose
+// named groups (file, line, col, severity, code, message) fill a Diagnostic.
+type regexParser struct {
+	name     string
+	patterns []*regexp.Regexp
+}
+
+// NewRegexParser builds a line-oriented parser from a pattern using the named
+// groups file, line, col, severity, code and message; only message is required.
+func NewRegexParser(name, pattern string) (DiagnosticParser, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("diagnostic pattern %q: %w", pattern, err)
+	}
+	if re.SubexpIndex("message") < 0 {
+		return nil, fmt.Errorf("diagnostic pattern %q has no (?P<message>...) group", pattern)
+	}
+	return &regexParser{name: name, patterns: []*regexp.Regexp{re}}, nil
+}
+
+func (r *regexParser) Name() string { return r.name }
+
+func (r *regexParser) Parse(output string) []Diagnostic {
+	var diags []Diagnostic
+	for line := range strings.SplitSeq(ansiEscape.ReplaceAllString(output, ""), "\n") {
+		line = strings.TrimRight(line, "\r")
+		for _, re := range r.patterns {
+			m := re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			group := func(name string) string {
+				if i := re.SubexpIndex(name); i >= 0 {
+					return m[i]
+				}
+				return ""
+			}
+			lineNo, _ := strconv.Atoi(group("line"))
+			col, _ := strconv.Atoi(group("col"))
+			diags = append(diags, Diagnostic{
+				Parser:   r.name,
+				File:     group("file"),
+				Line:     lineNo,
+				Column:   col,
+				Severity: group("severity"),
+				Code:     group("code"),
+				Message:  strings.TrimSpace(group("message")),
+			})
+			break
+		}
+	}
+	return diags
+}
+
+// ansiEscape matches terminal color sequences, which tools like tsc --pretty
+// wrap around file names and severities.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// builtinParsers are the parsers selectable by name from a config file.
+var builtinParsers = map[string]*regexParser{
+	// go build / go test compile errors: ./main.go:12:5: undefined: foo
+	"go": {name: "go", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`^(?P<file>[^\s:][^:]*\.go):(?P<line>\d+)(?::(?P<col>\d+))?: (?P<message>.+)$`),
+	}},
+	// go vet, which may prefix findings with "vet: ".
+	"govet": {name: "govet", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`^(?:vet: )?(?P<file>[^\s:][^:]*\.go):(?P<line>\d+)(?::(?P<col>\d+))?: (?P<message>.+)$`),
+	}},
+	// tsc, in both its plain and --pretty output forms.
+	"tsc": {name: "tsc", patterns: []*regexp.Regexp{
+		regexp.MustCompile(`^(?P<file>[^\s(][^(]*)\((?P<line>\d+),(?P<col>\d+)\): (?P<severity>error|warning) (?P<code>TS\d+): (?P<message>.+)$`),
+		regexp.MustCompile(`^(?P<file>[^\s:][^:]*):(?P<line>\d+):(?P<col>\d+) - (?P<severity>error|warning) (?P<code>TS\d+): (?P<message>.+)$`),
+	}},
+}
+
+// LookupParser resolves a parser from its config name: one of the built-ins
+// ("go", "govet", "tsc") or "regex:<pattern>" for a custom line pattern.
+func LookupParser(name string) (DiagnosticParser, error) {
+	if pattern, ok := strings.CutPrefix(name, "regex:"); ok {
+		return NewRegexParser("regex", pattern)
+	}
+	if p, ok := builtinParsers[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("unknown diagnostic parser %q (want go, govet, tsc or regex:<pattern>)", name)
+}
+
+// parseDiagnostics runs every parser over a step's output, tagging each result
+// with the step's name.
+func parseDiagnostics(name string, parsers []DiagnosticParser, output string) []Diagnostic {
+	var diags []Diagnostic
+	for _, parser := range parsers {
+		for _, d := range parser.Parse(output) {
+			d.Process = name
+			diags = append(diags, d)
+		}
+	}
+	return diags
+}
+
+// captureLimit bounds how much of a step's output is held for diagnostic
+// parsing; compilers report their errors last, so the tail is what matters.
+const captureLimit = 64 << 10
+
+// tailBuffer is a goroutine-safe writer that keeps the last captureLimit bytes
+// written to it. Both streams of a run share one, since compilers split their
+// errors across stdout and stderr inconsistently.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, b...)
+	if over := len(t.buf) - captureLimit; over > 0 {
+		t.buf = t.buf[over:]
+	}
+	return len(b), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
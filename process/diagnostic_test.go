@@ -0,0 +1,77 @@
+package process
+
+import "testing"
+
+func TestBuiltinParsers(t *testing.T) {
+	tests := []struct {
+		parser string
+		output string
+		want   Diagnostic
+	}{
+		{
+			parser: "go",
+			output: "# example.com/app\n./main.go:12:5: undefined: foo\n",
+			want:   Diagnostic{Parser: "go", File: "./main.go", Line: 12, Column: 5, Message: "undefined: foo"},
+		},
+		{
+			parser: "govet",
+			output: "vet: internal/x.go:3:2: unreachable code\n",
+			want:   Diagnostic{Parser: "govet", File: "internal/x.go", Line: 3, Column: 2, Message: "unreachable code"},
+		},
+		{
+			parser: "tsc",
+			output: "src/app.ts(7,14): error TS2322: Type 'string' is not assignable to type 'number'.\n",
+			want: Diagnostic{Parser: "tsc", File: "src/app.ts", Line: 7, Column: 14, Severity: "error", Code: "TS2322",
+				Message: "Type 'string' is not assignable to type 'number'."},
+		},
+		{
+			parser: "tsc",
+			output: "\x1b[96msrc/app.ts\x1b[0m:\x1b[93m7\x1b[0m:\x1b[93m14\x1b[0m - \x1b[91merror\x1b[0m\x1b[90m TS2322: \x1b[0mBad type.\n",
+			want:   Diagnostic{Parser: "tsc", File: "src/app.ts", Line: 7, Column: 14, Severity: "error", Code: "TS2322", Message: "Bad type."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.parser, func(t *testing.T) {
+			p, err := LookupParser(tt.parser)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := p.Parse(tt.output)
+			if len(got) != 1 {
+				t.Fatalf("Parse = %+v, want one diagnostic", got)
+			}
+			if got[0] != tt.want {
+				t.Errorf("Parse = %+v, want %+v", got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexParser(t *testing.T) {
+	p, err := LookupParser(`regex:^(?P<file>\S+) line (?P<line>\d+): (?P<message>.+)$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := p.Parse("noise\nschema.sql line 4: syntax error\n")
+	if len(got) != 1 || got[0].File != "schema.sql" || got[0].Line != 4 || got[0].Message != "syntax error" {
+		t.Errorf("Parse = %+v", got)
+	}
+
+	if _, err := LookupParser("regex:(?P<file>.+)"); err == nil {
+		t.Error("expected an error for a pattern without a message group")
+	}
+	if _, err := LookupParser("javac"); err == nil {
+		t.Error("expected an error for an unknown parser name")
+	}
+}
+
+func TestTailBufferKeepsTail(t *testing.T) {
+	var b tailBuffer
+	big := make([]byte, captureLimit)
+	_, _ = b.Write(big)
+	_, _ = b.Write([]byte("last"))
+	s := b.String()
+	if len(s) != captureLimit || s[len(s)-4:] != "last" {
+		t.Errorf("tail buffer length %d, suffix %q; want %d ending in last", len(s), s[len(s)-4:], captureLimit)
+	}
+}
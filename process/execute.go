@@ -1,24 +1,88 @@
 package process
 
 import (
+	"cmp"
+	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Execute struct {
 	// Name is a stable, human-meaningful identifier for the process. Consumers
 	// (e.g. a TUI) use it as the key for a per-process log pane. Optional; when
 	// empty it defaults to the command string.
-	Name      string `toml:"name"       yaml:"name"`
-	Cmd       string `toml:"cmd"        yaml:"cmd"`        // Execute command
-	ChangeDir string `toml:"dir"        yaml:"dir"`        // If directory needs to be changed to call this command relative to the root path
-	DelayNext int    `toml:"delay_next" yaml:"delay_next"` // Pause in ms held after this step completes, before the next process starts
+	Name      string `toml:"name"       yaml:"name"       json:"name"`
+	Cmd       string `toml:"cmd"        yaml:"cmd"        json:"cmd"`        // Execute command, run through the shell
+	ChangeDir string `toml:"dir"        yaml:"dir"        json:"dir"`        // If directory needs to be changed to call this command relative to the root path
+	DelayNext int    `toml:"delay_next" yaml:"delay_next" json:"delay_next"` // Pause in ms held after this step completes, before the next process starts
+	// Args is the argv form of the command, used instead of Cmd: Args[0] is
+	// looked up on PATH and run directly with the remaining arguments, with no
+	// shell in between to re-parse them or to receive signals meant for it.
+	Args []string `toml:"args" yaml:"args" json:"args"`
+	// Shell runs Cmd instead of the platform shell (/bin/sh -c, or cmd /C on
+	// Windows), e.g. "bash -lc" or "zsh". The command string is appended as the
+	// last argument; a bare program is given -c.
+	Shell string `toml:"shell" yaml:"shell" json:"shell"`
 	// Type can have one of a few types to define how it reacts to a file change
 	// background -- runs once at startup and is killed when refresh is canceled
 	// once -- runs once at refresh startup but is blocking
 	// blocking -- runs every refresh cycle as a blocking process
 	// primary -- Is the primary process that kills the previous processes before running
-	Type ExecuteType `toml:"type"       yaml:"type"`
+	// periodic -- runs to completion on its Schedule, independent of reloads
+	Type ExecuteType `toml:"type"       yaml:"type"       json:"type"`
+	// Diagnostics names the parsers run over this step's output when it fails:
+	// "go", "govet", "tsc", or "regex:<pattern>" (see LookupParser). Only
+	// blocking and once steps are parsed.
+	Diagnostics []string `toml:"diagnostics" yaml:"diagnostics" json:"diagnostics"`
+	// Listen lists TCP addresses (e.g. ":8080") that refresh binds itself and
+	// hands to each new instance of a primary or background process as
+	// inherited file descriptors 3, 4, ... following the systemd LISTEN_FDS
+	// convention. The sockets stay open across restarts, so clients queue
+	// instead of being refused while the process is replaced. Unix only.
+	Listen []string `toml:"listen" yaml:"listen" json:"listen"`
+	// RestartStrategy is how a primary is replaced on reload: "stop-first" (the
+	// default) stops the running instance before starting the new one, while
+	// "start-first" starts the new one, waits for it to be ready, and only then
+	// stops the old one, which keeps serving if the new one fails. Start-first
+	// needs the two instances to coexist, e.g. through Listen.
+	RestartStrategy RestartStrategy `toml:"restart_strategy" yaml:"restart_strategy" json:"restart_strategy"`
+	// Ready is the readiness check of a start-first restart: a "host:port" that
+	// must accept a TCP connection, or an http:// or https:// URL that must
+	// answer with a status below 400. When empty the new instance is ready once
+	// it has stayed up for a second.
+	Ready string `toml:"ready" yaml:"ready" json:"ready"`
+	// ReadyTimeout bounds the readiness check in milliseconds; 0 means 10s.
+	ReadyTimeout int `toml:"ready_timeout" yaml:"ready_timeout" json:"ready_timeout"`
+	// Ports lists TCP ports the process binds. Before a new instance of a
+	// primary starts, refresh waits until each is free, so a grandchild of the
+	// old instance still holding one does not make the new one fail to bind.
+	Ports []int `toml:"ports" yaml:"ports" json:"ports"`
+	// PortTimeout bounds the wait for Ports in milliseconds; 0 means 5s. When it
+	// passes, the step fails naming the process holding the port (Linux only).
+	PortTimeout int `toml:"port_timeout" yaml:"port_timeout" json:"port_timeout"`
+	// Inputs makes a blocking or once step incremental: glob patterns, relative
+	// to the step's directory, of the files it reads. ** matches any number of
+	// directories. The step is skipped when the files' contents and the command
+	// are unchanged since its last successful run, which is remembered under
+	// CacheDir across restarts.
+	Inputs []string `toml:"inputs" yaml:"inputs" json:"inputs"`
+	// Outputs lists glob patterns of the files an incremental step produces; the
+	// step also re-runs when they changed or went missing since that run.
+	Outputs []string `toml:"outputs" yaml:"outputs" json:"outputs"`
+	// Schedule is when a periodic task runs: an interval such as "10m", which
+	// first runs at startup, or a cron expression such as "0 * * * *" (see
+	// ParseSchedule).
+	Schedule string `toml:"schedule" yaml:"schedule" json:"schedule"`
+	// Limits caps the memory, CPU time and open files of the process and what
+	// it starts, and sets its CPU and I/O priority; see Limits.
+	Limits Limits `toml:"limits" yaml:"limits" json:"limits"`
+	// Parsers are additional diagnostic parsers supplied in code by SDK users.
+	Parsers []DiagnosticParser `toml:"-" yaml:"-" json:"-"`
 }
 
 type ExecuteType string
@@ -28,6 +92,7 @@ var (
 	Once       ExecuteType = "once"
 	Blocking   ExecuteType = "blocking"
 	Primary    ExecuteType = "primary"
+	Periodic   ExecuteType = "periodic"
 )
 
 var KILL_STALE = Execute{
@@ -38,12 +103,69 @@ var KILL_STALE = Execute{
 var REFRESH_EXEC = "REFRESH"
 var KILL_EXEC = "KILL_STALE"
 
-// generateExec builds a command run through the platform shell, so command
-// strings may use quoting, pipes, &&, and redirection rather than being a bare
-// argv split on spaces.
-func generateExec(cmd string) *exec.Cmd {
-	shell, args := shellInvocation(cmd)
-	return exec.Command(shell, args...)
+// Command returns the execute's command for display and as its default name:
+// Cmd, or Args joined with spaces, quoting arguments that need it.
+func (e Execute) Command() string {
+	if len(e.Args) == 0 {
+		return e.Cmd
+	}
+	quoted := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// generateExec builds the command for a spec. Args are exec'd directly, while a
+// Cmd string runs through the shell, so it may use quoting, pipes, &&, and
+// redirection rather than being a bare argv split on spaces.
+func generateExec(spec Execute) *exec.Cmd {
+	return command(context.Background(), spec)
+}
+
+// commandWaitDelay bounds how long a CommandContext command's Wait waits for
+// its output to close once it is killed, in case something it started left
+// the process group holding it open.
+const commandWaitDelay = 2 * time.Second
+
+// CommandContext returns the command running spec the way the ProcessManager
+// runs it, Args directly or Cmd through its Shell, in its own process group.
+// When ctx is done the whole group is killed, not just the shell, and Wait
+// returns within a short delay even if the output is still held open. It suits
+// one-off commands such as the engine's hooks.
+func CommandContext(ctx context.Context, spec Execute) *exec.Cmd {
+	cmd := command(ctx, spec)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessTree(cmd) }
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
+
+func command(ctx context.Context, spec Execute) *exec.Cmd {
+	if len(spec.Args) > 0 {
+		return exec.CommandContext(ctx, spec.Args[0], spec.Args[1:]...)
+	}
+	shell, args := shellInvocation(spec.Shell, spec.Cmd)
+	return exec.CommandContext(ctx, shell, args...)
+}
+
+// shellInvocation returns the program and arguments running command through
+// shell, or through the platform's defaultShell when shell is empty. A shell
+// given as a bare program is passed -c, or /C for cmd.exe.
+func shellInvocation(shell, command string) (string, []string) {
+	fields := strings.Fields(cmp.Or(strings.TrimSpace(shell), defaultShell))
+	args := fields[1:]
+	if len(args) == 0 {
+		flag := "-c"
+		if name := strings.ToLower(filepath.Base(fields[0])); name == "cmd" || name == "cmd.exe" {
+			flag = "/C"
+		}
+		args = []string{flag}
+	}
+	return fields[0], append(slices.Clone(args), command)
 }
 
 func stringToExecuteType(typing string) (ExecuteType, error) {
@@ -56,6 +178,8 @@ func stringToExecuteType(typing string) (ExecuteType, error) {
 		return Blocking, nil
 	case "primary":
 		return Primary, nil
+	case "periodic":
+		return Periodic, nil
 	default:
 		return "", fmt.Errorf("execute type of %q is invalid", typing)
 	}
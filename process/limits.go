@@ -0,0 +1,309 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Limits caps the resources of a process and everything it starts, so a
+// runaway process cannot take the machine down with it. Zero values leave a
+// resource unlimited. The rlimits (memory, CPU time, open files) and nice are
+// Unix only; ionice and cgroup are Linux only. On Linux the rlimits are set on
+// the process with prlimit(2) once it has started; on macOS a shell sets them
+// before it runs the command.
+type Limits struct {
+	// MemoryMB caps the memory of the process and everything it starts, in
+	// megabytes. Under a cgroup it is the group's memory.max. Otherwise, on
+	// Linux, refresh samples the group's resident memory and kills it when it
+	// exceeds the limit. Elsewhere it caps the data segment (RLIMIT_DATA) so
+	// allocations beyond it fail, which does not bound runtimes that allocate
+	// with mmap, such as Go.
+	MemoryMB int `toml:"memory_mb"   yaml:"memory_mb"   json:"memory_mb"`
+	// CPUSeconds caps the CPU time of the process in seconds (RLIMIT_CPU); it
+	// is killed with SIGXCPU when it uses more.
+	CPUSeconds int `toml:"cpu_seconds" yaml:"cpu_seconds" json:"cpu_seconds"`
+	// OpenFiles caps the number of open file descriptors (RLIMIT_NOFILE).
+	OpenFiles int `toml:"open_files"  yaml:"open_files"  json:"open_files"`
+	// Nice is the scheduling priority, from -20 (highest) to 19 (lowest).
+	// Negative values need privileges.
+	Nice int `toml:"nice"        yaml:"nice"        json:"nice"`
+	// IONice is the I/O scheduling class: "idle", "best-effort" or "realtime",
+	// optionally followed by ":<level>" from 0 (highest) to 7.
+	IONice string `toml:"ionice"      yaml:"ionice"      json:"ionice"`
+	// Cgroup starts each instance in its own cgroup v2 group, when refresh can
+	// create one, enforcing MemoryMB as the group's memory.max and counting the
+	// memory of every process in it. Refresh moves itself into a leaf group of
+	// its own cgroup if that is needed to manage sub-groups and it is alone in
+	// that cgroup.
+	Cgroup bool `toml:"cgroup"      yaml:"cgroup"      json:"cgroup"`
+}
+
+// set reports whether any limit is configured.
+func (l Limits) set() bool {
+	return l != Limits{}
+}
+
+// rlimits reports whether any limit is applied with setrlimit.
+func (l Limits) rlimits() bool {
+	return l.MemoryMB > 0 || l.CPUSeconds > 0 || l.OpenFiles > 0
+}
+
+// Validate checks the limits' values.
+func (l Limits) Validate() error {
+	switch {
+	case l.MemoryMB < 0:
+		return fmt.Errorf("memory_mb must not be negative (got %d)", l.MemoryMB)
+	case l.CPUSeconds < 0:
+		return fmt.Errorf("cpu_seconds must not be negative (got %d)", l.CPUSeconds)
+	case l.OpenFiles < 0:
+		return fmt.Errorf("open_files must not be negative (got %d)", l.OpenFiles)
+	case l.Nice < -20 || l.Nice > 19:
+		return fmt.Errorf("nice must be between -20 and 19 (got %d)", l.Nice)
+	}
+	_, err := parseIONice(l.IONice)
+	return err
+}
+
+// I/O scheduling classes, as numbered by ioprio_set(2).
+const (
+	ioClassRealtime   = 1
+	ioClassBestEffort = 2
+	ioClassIdle       = 3
+)
+
+// parseIONice parses an IONice value into the ioprio_set(2) priority, 0 when
+// it is empty.
+func parseIONice(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	name, levelStr, hasLevel := strings.Cut(s, ":")
+	var class int
+	switch name {
+	case "realtime":
+		class = ioClassRealtime
+	case "best-effort":
+		class = ioClassBestEffort
+	case "idle":
+		class = ioClassIdle
+	default:
+		return 0, fmt.Errorf("invalid ionice class %q (want idle, best-effort or realtime)", name)
+	}
+	level := 4
+	if hasLevel {
+		n, err := strconv.Atoi(levelStr)
+		if err != nil || n < 0 || n > 7 || class == ioClassIdle {
+			return 0, fmt.Errorf("invalid ionice %q (level 0-7, not for idle)", s)
+		}
+		level = n
+	}
+	if class == ioClassIdle {
+		level = 0
+	}
+	return class<<13 | level, nil
+}
+
+// FailureReason tells why a failed process ended, beyond its exit status.
+type FailureReason string
+
+const (
+	// ReasonMemoryLimit means the process was killed for exceeding
+	// Limits.MemoryMB, by its cgroup or refresh's memory watch.
+	ReasonMemoryLimit FailureReason = "memory-limit"
+	// ReasonCPULimit means the process was killed for exceeding
+	// Limits.CPUSeconds.
+	ReasonCPULimit FailureReason = "cpu-limit"
+)
+
+// LimitError is the error of a process that a resource limit ended. Its
+// failed ProcessEvent carries the Reason.
+type LimitError struct {
+	Reason FailureReason
+	Err    error
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("exceeded its %s: %v", strings.ReplaceAll(string(e.Reason), "-", " "), e.Err)
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// limiter applies a process's Limits to one instance: the cgroup before it
+// starts, the priorities as it starts on Linux, the rest once it has a pid.
+type limiter struct {
+	limits Limits
+	exec   string
+	cgroup *cgroup
+	// rlimits are set on the instance once it has started, unless a shell
+	// sets them before it runs.
+	rlimits Limits
+	// wrapped is set when the instance runs under the shell that sets its
+	// rlimits.
+	wrapped bool
+	// watchRSS enforces MemoryMB with a memoryWatch, started with the instance.
+	watchRSS bool
+	memory   *memoryWatch
+}
+
+// memoryWatch enforces a memory limit by sampling; see watchMemory.
+type memoryWatch struct {
+	pgid     int
+	max      uint64
+	exceeded atomic.Bool
+}
+
+// stop ends the watch; once it returns, the watch kills nothing.
+func (w *memoryWatch) stop() {
+	if w != nil {
+		unwatchMemory(w)
+	}
+}
+
+// killed reports whether the watch killed the group for exceeding its limit.
+func (w *memoryWatch) killed() bool {
+	return w != nil && w.exceeded.Load()
+}
+
+// applyLimits prepares cmd to run under p's limits. It returns nil when p has
+// none. Limits that are unavailable here are logged and skipped, except
+// rlimits on a platform without them, which fail the start.
+func (pm *ProcessManager) applyLimits(p *Process, cmd *exec.Cmd) (*limiter, error) {
+	limits := p.spec.Limits
+	if !limits.set() {
+		return nil, nil
+	}
+	l := &limiter{limits: limits, exec: p.Exec}
+	if limits.Cgroup {
+		cg, err := newCgroup(p.displayName(), limits)
+		if err == nil {
+			err = cg.attach(cmd)
+			if err != nil {
+				_ = cg.remove()
+			}
+		}
+		if err != nil {
+			slog.Warn("not using a cgroup", "exec", p.Exec, "err", err)
+		} else {
+			l.cgroup = cg
+			// The group enforces the memory limit; a data rlimit would only
+			// make allocations fail before it does.
+			limits.MemoryMB = 0
+		}
+	}
+	if limits.MemoryMB > 0 && metricsSupported {
+		// Resident memory can be sampled here, which bounds what the process
+		// actually uses rather than the address space it reserves.
+		l.watchRSS = true
+		limits.MemoryMB = 0
+	}
+	if limits.rlimits() {
+		wrapped, err := prepareRlimits(cmd, limits)
+		if err != nil {
+			l.release()
+			return nil, err
+		}
+		l.wrapped = wrapped
+		if !wrapped {
+			l.rlimits = limits
+		}
+	}
+	return l, nil
+}
+
+// started applies the limits that need the instance's pid. Failures are logged.
+func (l *limiter) started(pid int) {
+	if l == nil {
+		return
+	}
+	if l.cgroup != nil {
+		if err := l.cgroup.started(pid); err != nil {
+			slog.Warn("moving the process into its cgroup", "exec", l.exec, "err", err)
+		}
+	}
+	if l.rlimits.rlimits() {
+		if err := setRlimits(pid, l.rlimits); err != nil {
+			slog.Warn("setting process limits", "exec", l.exec, "err", err)
+		}
+	}
+	if l.watchRSS {
+		l.memory = watchMemory(pid, uint64(l.limits.MemoryMB)<<20)
+	}
+	if !prioritiesInherited {
+		l.prioritize(pid)
+	}
+}
+
+// start starts cmd, on Linux with its priorities already set.
+func (l *limiter) start(cmd *exec.Cmd) error {
+	if l == nil {
+		return cmd.Start()
+	}
+	if l.cgroup != nil {
+		return l.cgroup.start(cmd, l.startCmd)
+	}
+	return l.startCmd(cmd)
+}
+
+// startCmd starts cmd with the priorities where they can be set first.
+func (l *limiter) startCmd(cmd *exec.Cmd) error {
+	if l.limits.Nice == 0 && l.limits.IONice == "" {
+		return cmd.Start()
+	}
+	return startPrioritized(cmd, l.prioritize)
+}
+
+// prioritize sets the priorities of the process, or thread, with the id.
+// Failures are logged.
+func (l *limiter) prioritize(id int) {
+	if l.limits.Nice != 0 {
+		if err := setNice(id, l.limits.Nice); err != nil {
+			slog.Warn("setting process priority", "exec", l.exec, "nice", l.limits.Nice, "err", err)
+		}
+	}
+	if l.limits.IONice != "" {
+		prio, _ := parseIONice(l.limits.IONice)
+		if err := setIONice(id, prio); err != nil {
+			slog.Warn("setting process I/O priority", "exec", l.exec, "ionice", l.limits.IONice, "err", err)
+		}
+	}
+}
+
+// exited wraps the error of a failed instance in a LimitError when a limit
+// ended it.
+func (l *limiter) exited(state *os.ProcessState, err error) error {
+	if l == nil || err == nil {
+		return err
+	}
+	var reason FailureReason
+	switch {
+	case l.memory.killed(), l.cgroup != nil && l.cgroup.oomKilled():
+		reason = ReasonMemoryLimit
+	case l.limits.CPUSeconds > 0 && cpuLimitHit(state, l.wrapped):
+		reason = ReasonCPULimit
+	default:
+		return err
+	}
+	slog.Warn("process exceeded its resource limit", "exec", l.exec, "limit", reason)
+	return &LimitError{Reason: reason, Err: err}
+}
+
+// release stops the memory watch and removes the instance's cgroup, killing
+// anything left in it.
+func (l *limiter) release() {
+	if l == nil {
+		return
+	}
+	l.memory.stop()
+	if l.cgroup == nil {
+		return
+	}
+	if err := l.cgroup.remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Debug("removing cgroup", "exec", l.exec, "err", err)
+	}
+}
@@ -0,0 +1,33 @@
+package process
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// wrapRlimits makes cmd set its rlimits before it runs. macOS has no
+// prlimit(2) to set them on the child, so a shell applies them with ulimit and
+// execs the command in its place; the limits hold for the program and are
+// inherited by everything it starts. Soft limits are set, so a CPU limit ends
+// the process with SIGXCPU.
+func wrapRlimits(cmd *exec.Cmd, limits Limits) error {
+	if cmd.Err != nil {
+		return nil // Start reports it
+	}
+	var script strings.Builder
+	if limits.MemoryMB > 0 {
+		fmt.Fprintf(&script, "ulimit -S -d %d && ", limits.MemoryMB*1024)
+	}
+	if limits.CPUSeconds > 0 {
+		fmt.Fprintf(&script, "ulimit -S -t %d && ", limits.CPUSeconds)
+	}
+	if limits.OpenFiles > 0 {
+		fmt.Fprintf(&script, "ulimit -S -n %d && ", limits.OpenFiles)
+	}
+	script.WriteString(`exec "$0" "$@"`)
+	argv := append([]string{cmd.Path}, cmd.Args[1:]...)
+	sh := exec.Command("/bin/sh", append([]string{"-c", script.String()}, argv...)...)
+	cmd.Path, cmd.Args, cmd.Err = sh.Path, sh.Args, sh.Err
+	return nil
+}
@@ -0,0 +1,404 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioprioWhoProcess makes ioprio_set(2) target a single process.
+const ioprioWhoProcess = 1
+
+// setIONice sets the I/O priority of the process, or of the thread with that
+// id.
+func setIONice(pid, prio int) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(prio))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// prioritiesInherited reports that startPrioritized starts a process with its
+// priorities already set.
+const prioritiesInherited = true
+
+// startPrioritized starts cmd from a thread that first has prioritize set its
+// priorities. Nice and the I/O priority are per thread on Linux and the child
+// inherits them from the thread that forks it, so they hold before it runs.
+// The thread stays locked, so it exits with the goroutine rather than running
+// refresh with them.
+func startPrioritized(cmd *exec.Cmd, prioritize func(tid int)) error {
+	errc := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		prioritize(syscall.Gettid())
+		errc <- cmd.Start()
+	}()
+	return <-errc
+}
+
+// rlimit is struct rlimit64, as prlimit64(2) reads and writes it.
+type rlimit struct {
+	cur, max uint64
+}
+
+// prlimit gets the process's limit on resource into old and, when new is not
+// nil, sets it.
+func prlimit(pid, resource int, new, old *rlimit) error {
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64, uintptr(pid), uintptr(resource),
+		uintptr(unsafe.Pointer(new)), uintptr(unsafe.Pointer(old)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// rlimitValue is a soft limit to set on one resource.
+type rlimitValue struct {
+	name     string
+	resource int
+	value    uint64
+}
+
+// rlimitValues lists the soft limits to set for limits.
+func rlimitValues(limits Limits) []rlimitValue {
+	var values []rlimitValue
+	if limits.MemoryMB > 0 {
+		values = append(values, rlimitValue{"memory_mb", syscall.RLIMIT_DATA, uint64(limits.MemoryMB) << 20})
+	}
+	if limits.CPUSeconds > 0 {
+		values = append(values, rlimitValue{"cpu_seconds", syscall.RLIMIT_CPU, uint64(limits.CPUSeconds)})
+	}
+	if limits.OpenFiles > 0 {
+		values = append(values, rlimitValue{"open_files", syscall.RLIMIT_NOFILE, uint64(limits.OpenFiles)})
+	}
+	return values
+}
+
+// prepareRlimits checks the rlimits fit under the hard limits the instance
+// inherits from refresh. They are set with setRlimits once it has started, so
+// cmd runs unwrapped.
+func prepareRlimits(cmd *exec.Cmd, limits Limits) (wrapped bool, err error) {
+	for _, v := range rlimitValues(limits) {
+		var lim rlimit
+		if err := prlimit(0, v.resource, nil, &lim); err != nil {
+			return false, fmt.Errorf("reading the %s hard limit: %w", v.name, err)
+		}
+		if v.value > lim.max {
+			return false, fmt.Errorf("%s is above the hard limit refresh runs under", v.name)
+		}
+	}
+	return false, nil
+}
+
+// setRlimits sets the soft rlimits of a started process, keeping its hard
+// limits, so a CPU limit ends it with SIGXCPU. Everything it starts from then
+// on inherits them.
+func setRlimits(pid int, limits Limits) error {
+	for _, v := range rlimitValues(limits) {
+		var lim rlimit
+		if err := prlimit(pid, v.resource, nil, &lim); err != nil {
+			return err
+		}
+		lim.cur = min(v.value, lim.max)
+		if err := prlimit(pid, v.resource, &lim, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup is the cgroup v2 group of one process instance.
+type cgroup struct {
+	dir string
+	// fd is the open group directory the instance is started into; nil once
+	// it has started.
+	fd *os.File
+	// late is set when the instance joins the group once it has started.
+	late bool
+}
+
+// delegated is refresh's own cgroup, prepared once to hold instance groups.
+var delegated struct {
+	once sync.Once
+	dir  string
+	err  error
+}
+
+// newCgroup creates a group for an instance of the named process, under
+// refresh's own cgroup, with the memory limit applied.
+func newCgroup(name string, limits Limits) (*cgroup, error) {
+	delegated.once.Do(func() { delegated.dir, delegated.err = delegateCgroup() })
+	if delegated.err != nil {
+		return nil, delegated.err
+	}
+	dir, err := os.MkdirTemp(delegated.dir, cgroupName(name)+"-")
+	if err != nil {
+		return nil, err
+	}
+	cg := &cgroup{dir: dir}
+	if limits.MemoryMB > 0 {
+		if err := cg.write("memory.max", strconv.Itoa(limits.MemoryMB<<20)); err != nil {
+			_ = cg.remove()
+			return nil, err
+		}
+		// Swapping would let the group slowly exceed the limit instead.
+		_ = cg.write("memory.swap.max", "0")
+	}
+	return cg, nil
+}
+
+// delegateCgroup returns refresh's cgroup with the memory controller enabled
+// for its children. A group holding processes cannot enable controllers for
+// its children, so when refresh is alone in its group it first moves itself
+// into a "refresh" leaf group. It never moves other processes, such as the
+// shell that started it in a shared user or container group.
+func delegateCgroup() (string, error) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	rel, found := "", false
+	for line := range strings.Lines(string(data)) {
+		if path, ok := strings.CutPrefix(strings.TrimSpace(line), "0::"); ok {
+			rel, found = path, true
+		}
+	}
+	if !found {
+		return "", errors.New("cgroup v2 is not in use")
+	}
+	dir := filepath.Join(cgroupRoot, rel)
+	controllers, err := os.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil || !slices.Contains(strings.Fields(string(controllers)), "memory") {
+		return "", fmt.Errorf("the memory controller is not available in %s", dir)
+	}
+	enable := func() error {
+		return os.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+memory"), 0o644)
+	}
+	if enable() == nil {
+		return dir, nil
+	}
+	procs, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	self := strconv.Itoa(os.Getpid())
+	if pids := strings.Fields(string(procs)); len(pids) != 1 || pids[0] != self {
+		return "", fmt.Errorf("refresh shares its cgroup %s with other processes, which it will not move; run it in a cgroup of its own, e.g. with systemd-run --user --scope -p Delegate=yes", dir)
+	}
+	leaf := filepath.Join(dir, "refresh")
+	if err := os.Mkdir(leaf, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(self), 0o644); err != nil {
+		return "", fmt.Errorf("moving refresh to %s: %w", leaf, err)
+	}
+	if err := enable(); err != nil {
+		return "", fmt.Errorf("enabling the memory controller in %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// cgroupName turns a process name into a group name prefix.
+func cgroupName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+			return r
+		}
+		return '_'
+	}, name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return "refresh-" + name
+}
+
+func (cg *cgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(cg.dir, file), []byte(value), 0o644)
+}
+
+// attach makes cmd start inside the group (clone3 with CLONE_INTO_CGROUP,
+// Linux 5.7 or later), so nothing it forks can escape the limits by starting
+// before it joins. Once clone3 has turned out to be unavailable, the instance
+// joins the group when it has started instead.
+func (cg *cgroup) attach(cmd *exec.Cmd) error {
+	if cloneIntoCgroupUnavailable.Load() {
+		cg.late = true
+		return nil
+	}
+	fd, err := os.Open(cg.dir)
+	if err != nil {
+		return err
+	}
+	cg.fd = fd
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+	return nil
+}
+
+// cloneIntoCgroupUnavailable is set once clone3 has failed to start an
+// instance inside its group, as it does before Linux 5.7 or under a seccomp
+// filter that blocks clone3.
+var cloneIntoCgroupUnavailable atomic.Bool
+
+// start starts cmd with start. When clone3 fails to start it inside the
+// group, it starts cmd again without it, and started moves the instance in.
+func (cg *cgroup) start(cmd *exec.Cmd, start func(*exec.Cmd) error) error {
+	if cg.late {
+		return start(cmd)
+	}
+	unstarted := *cmd
+	err := start(cmd)
+	if err == nil || !cloneUnsupported(err) {
+		return err
+	}
+	if !cloneIntoCgroupUnavailable.Swap(true) {
+		slog.Warn("clone3 cannot start processes in their cgroup; moving them in once started", "err", err)
+	}
+	_ = cg.started(0)
+	cg.late = true
+	*cmd = unstarted
+	cmd.SysProcAttr.UseCgroupFD = false
+	cmd.SysProcAttr.CgroupFD = 0
+	return start(cmd)
+}
+
+// cloneUnsupported reports whether a start failed because clone3 or
+// CLONE_INTO_CGROUP is unavailable.
+func cloneUnsupported(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.ENOSYS, syscall.EINVAL, syscall.E2BIG, syscall.EPERM} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// started releases the group directory once the instance runs in the group,
+// or moves the instance with the pid into it when it could not start there.
+func (cg *cgroup) started(pid int) error {
+	if cg.fd != nil {
+		_ = cg.fd.Close()
+		cg.fd = nil
+	}
+	if cg.late && pid > 0 {
+		return cg.write("cgroup.procs", strconv.Itoa(pid))
+	}
+	return nil
+}
+
+// oomKilled reports whether the kernel killed a process of the group for
+// exceeding its memory limit.
+func (cg *cgroup) oomKilled() bool {
+	data, err := os.ReadFile(filepath.Join(cg.dir, "memory.events"))
+	if err != nil {
+		return false
+	}
+	for line := range strings.Lines(string(data)) {
+		if count, ok := strings.CutPrefix(strings.TrimSpace(line), "oom_kill "); ok {
+			return count != "0"
+		}
+	}
+	return false
+}
+
+// remove kills whatever is left in the group, such as a daemonized
+// grandchild, and deletes it once it is empty.
+func (cg *cgroup) remove() error {
+	_ = cg.started(0)
+	_ = cg.write("cgroup.kill", "1")
+	var err error
+	for range 50 {
+		if err = os.Remove(cg.dir); err == nil || !errors.Is(err, syscall.EBUSY) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+// memoryWatchInterval is how often the watched groups' memory is sampled.
+const memoryWatchInterval = 100 * time.Millisecond
+
+// memoryWatches are the groups watchMemory enforces a limit on. One goroutine
+// samples them all, with a single scan of /proc per tick, while there are any.
+var memoryWatches struct {
+	sync.Mutex
+	watches  []*memoryWatch
+	sampling bool
+}
+
+// watchMemory enforces a memory limit without a cgroup: it samples the
+// resident memory of the process group pgid and kills the group once it
+// exceeds max bytes.
+func watchMemory(pgid int, max uint64) *memoryWatch {
+	w := &memoryWatch{pgid: pgid, max: max}
+	memoryWatches.Lock()
+	defer memoryWatches.Unlock()
+	memoryWatches.watches = append(memoryWatches.watches, w)
+	if !memoryWatches.sampling {
+		memoryWatches.sampling = true
+		go sampleMemory()
+	}
+	return w
+}
+
+func unwatchMemory(w *memoryWatch) {
+	memoryWatches.Lock()
+	defer memoryWatches.Unlock()
+	memoryWatches.watches = slices.DeleteFunc(memoryWatches.watches, func(x *memoryWatch) bool { return x == w })
+}
+
+// sampleMemory kills the watched groups that exceed their limit, until no
+// watch is left. Kills happen under the lock, so none follows unwatchMemory.
+func sampleMemory() {
+	ticker := time.NewTicker(memoryWatchInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		memoryWatches.Lock()
+		if len(memoryWatches.watches) == 0 {
+			memoryWatches.sampling = false
+			memoryWatches.Unlock()
+			return
+		}
+		pgids := make([]int, len(memoryWatches.watches))
+		for i, w := range memoryWatches.watches {
+			pgids[i] = w.pgid
+		}
+		memoryWatches.Unlock()
+
+		usage, err := sampleGroups(pgids, false)
+		if err != nil {
+			continue
+		}
+		memoryWatches.Lock()
+		memoryWatches.watches = slices.DeleteFunc(memoryWatches.watches, func(w *memoryWatch) bool {
+			if u, ok := usage[w.pgid]; !ok || u.rss <= w.max {
+				return false
+			}
+			w.exceeded.Store(true)
+			_ = syscall.Kill(-w.pgid, syscall.SIGKILL)
+			return true
+		})
+		memoryWatches.Unlock()
+	}
+}
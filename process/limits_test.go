@@ -0,0 +1,156 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestResourceLimits checks the rlimits and priority reach the process, and
+// that exceeding the CPU limit is reported as such.
+func TestResourceLimits(t *testing.T) {
+	var reasons []FailureReason
+	pm := NewProcessManager()
+	pm.OnEvent = func(ev ProcessEvent) {
+		if ev.Info.State == StateFailed {
+			reasons = append(reasons, ev.Reason)
+		}
+	}
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	limited := `sleep 0.2; test "$(ulimit -n)" = 64 && test "$(nice)" = 5`
+	if runtime.GOOS == "linux" {
+		// The priority is in place before the command runs.
+		limited = `test "$(nice)" = 5 && ` + limited
+	}
+	specs := []Execute{
+		{
+			Name:   "limited",
+			Cmd:    limited,
+			Type:   Blocking,
+			Limits: Limits{OpenFiles: 64, Nice: 5},
+		},
+		{
+			Name:   "spin",
+			Cmd:    "while :; do :; done",
+			Type:   Blocking,
+			Limits: Limits{CPUSeconds: 1},
+		},
+	}
+	for _, spec := range specs {
+		if err := pm.AddProcessSpec(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := pm.Start(context.Background())
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Reason != ReasonCPULimit {
+		t.Fatalf("Start = %v, want the spin step to exceed its CPU limit", err)
+	}
+	if info := pm.Snapshot()[0]; info.State != StateExited {
+		t.Errorf("limited step %s, want it to see its limits", info.State)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonCPULimit {
+		t.Errorf("failure reasons = %v, want [%s]", reasons, ReasonCPULimit)
+	}
+}
+
+// TestExitCodeIsNotCPULimit checks a process that merely exits with the
+// status a shell reports for SIGXCPU is not taken to have hit its CPU limit.
+func TestExitCodeIsNotCPULimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("elsewhere a shell sets the rlimits and reports SIGXCPU as 152")
+	}
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	spec := Execute{Name: "exit", Cmd: "exit 152", Type: Blocking, Limits: Limits{CPUSeconds: 60}}
+	if err := pm.AddProcessSpec(spec); err != nil {
+		t.Fatal(err)
+	}
+	err := pm.Start(context.Background())
+	var limitErr *LimitError
+	if err == nil || errors.As(err, &limitErr) {
+		t.Fatalf("Start = %v, want a plain failure", err)
+	}
+}
+
+// TestRlimitAboveHardLimit checks a limit the process could not be given
+// fails its start.
+func TestRlimitAboveHardLimit(t *testing.T) {
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	spec := Execute{Name: "greedy", Cmd: "true", Type: Blocking, Limits: Limits{OpenFiles: 1 << 30}}
+	if err := pm.AddProcessSpec(spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Start(context.Background()); err == nil {
+		t.Fatal("Start succeeded, want the open file limit to be refused")
+	}
+	if info := pm.Snapshot()[0]; info.State != StateFailed {
+		t.Errorf("state %s, want failed", info.State)
+	}
+}
+
+// TestMemoryLimit checks a process group outgrowing memory_mb is killed and
+// reported as such, without a cgroup.
+func TestMemoryLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memory is only sampled on Linux")
+	}
+	var reasons []FailureReason
+	pm := NewProcessManager()
+	pm.OnEvent = func(ev ProcessEvent) {
+		if ev.Info.State == StateFailed {
+			reasons = append(reasons, ev.Reason)
+		}
+	}
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	err := pm.AddProcessSpec(Execute{
+		Name:   "leak",
+		Cmd:    `x=$(head -c 200000000 /dev/zero | tr '\0' a); sleep 5`,
+		Type:   Blocking,
+		Limits: Limits{MemoryMB: 50},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	err = pm.Start(context.Background())
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Reason != ReasonMemoryLimit {
+		t.Fatalf("Start = %v, want the step to exceed its memory limit", err)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("the step ran for %s, want it killed when it outgrew its limit", elapsed)
+	}
+	if len(reasons) != 1 || reasons[0] != ReasonMemoryLimit {
+		t.Errorf("failure reasons = %v, want [%s]", reasons, ReasonMemoryLimit)
+	}
+}
+
+func TestInvalidLimits(t *testing.T) {
+	for _, limits := range []Limits{
+		{MemoryMB: -1},
+		{Nice: 20},
+		{IONice: "fast"},
+		{IONice: "best-effort:9"},
+		{IONice: "idle:3"},
+	} {
+		err := NewProcessManager().AddProcessSpec(Execute{Cmd: "app", Type: Primary, Limits: limits})
+		if err == nil {
+			t.Errorf("accepted limits %+v", limits)
+		}
+	}
+}
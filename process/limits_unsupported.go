@@ -0,0 +1,57 @@
+//go:build !linux
+
+package process
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// setIONice is unsupported outside Linux.
+func setIONice(pid, prio int) error {
+	return errors.New("ionice is only supported on Linux")
+}
+
+// prioritiesInherited is false: priorities are set once the process has
+// started.
+const prioritiesInherited = false
+
+// startPrioritized only starts cmd.
+func startPrioritized(cmd *exec.Cmd, prioritize func(id int)) error {
+	return cmd.Start()
+}
+
+// prepareRlimits has a shell set the rlimits before the command runs, where
+// the platform supports them.
+func prepareRlimits(cmd *exec.Cmd, limits Limits) (wrapped bool, err error) {
+	if err := wrapRlimits(cmd, limits); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setRlimits is never needed outside Linux: prepareRlimits applies them.
+func setRlimits(pid int, limits Limits) error { return nil }
+
+// cgroup stands in for the Linux cgroup v2 group; newCgroup never returns one.
+type cgroup struct{}
+
+func newCgroup(name string, limits Limits) (*cgroup, error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
+
+func (cg *cgroup) attach(cmd *exec.Cmd) error { return nil }
+
+func (cg *cgroup) start(cmd *exec.Cmd, start func(*exec.Cmd) error) error { return start(cmd) }
+
+func (cg *cgroup) started(pid int) error { return nil }
+
+func (cg *cgroup) oomKilled() bool { return false }
+
+func (cg *cgroup) remove() error { return nil }
+
+// watchMemory is never used outside Linux, where memory_mb falls back to the
+// data rlimit.
+func watchMemory(pgid int, max uint64) *memoryWatch { return nil }
+
+func unwatchMemory(w *memoryWatch) {}
@@ -0,0 +1,76 @@
+package process
+
+import (
+	"errors"
+	"net"
+	"os"
+	"slices"
+)
+
+// listenerFiles returns the sockets to hand a process for its Listen
+// addresses, binding any not bound yet. Sockets stay bound across restarts, so
+// connections queue in the kernel while a process is replaced instead of being
+// refused. Each call returns fresh duplicates of the descriptors; the caller
+// closes them once the child has inherited them.
+func (pm *ProcessManager) listenerFiles(addrs []string) ([]*os.File, error) {
+	files := make([]*os.File, 0, len(addrs))
+	for _, addr := range addrs {
+		ln, ok := pm.listeners[addr]
+		if !ok {
+			l, err := net.Listen("tcp", addr)
+			if err != nil {
+				closeFiles(files)
+				return nil, err
+			}
+			tcp, ok := l.(*net.TCPListener)
+			if !ok {
+				l.Close()
+				closeFiles(files)
+				return nil, errors.New("listen " + addr + ": not a TCP listener")
+			}
+			if pm.listeners == nil {
+				pm.listeners = make(map[string]*net.TCPListener)
+			}
+			pm.listeners[addr] = tcp
+			ln = tcp
+		}
+		f, err := ln.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// releaseListeners closes the sockets no configured process listens on any
+// more, after a process was removed or its spec changed.
+func (pm *ProcessManager) releaseListeners() {
+	pm.mu.RLock()
+	var used []string
+	for _, p := range pm.Processes {
+		used = append(used, p.spec.Listen...)
+	}
+	pm.mu.RUnlock()
+	for addr, ln := range pm.listeners {
+		if !slices.Contains(used, addr) {
+			ln.Close()
+			delete(pm.listeners, addr)
+		}
+	}
+}
+
+// closeListeners closes every held socket on shutdown.
+func (pm *ProcessManager) closeListeners() {
+	for addr, ln := range pm.listeners {
+		ln.Close()
+		delete(pm.listeners, addr)
+	}
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,129 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestListenHelper is the primary run by TestListenHandoff: it serves HTTP on
+// the inherited socket and answers with its pid.
+func TestListenHelper(t *testing.T) {
+	if os.Getenv("REFRESH_LISTEN_HELPER") != "1" {
+		t.Skip("helper process for TestListenHandoff")
+	}
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) || os.Getenv("LISTEN_FDS") != "1" {
+		fmt.Fprintf(os.Stderr, "LISTEN_PID=%s LISTEN_FDS=%s, pid %d\n", os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getpid())
+		os.Exit(3)
+	}
+	ln, err := net.FileListener(os.NewFile(3, "listener"))
+	if err != nil {
+		os.Exit(4)
+	}
+	http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, os.Getpid())
+	}))
+}
+
+// TestListenHandoff checks the socket outlives primary restarts: requests made
+// while the primary is replaced are served by the next instance rather than
+// refused. Both command forms, including a command string with a leading
+// variable assignment, must hand the program itself LISTEN_PID.
+func TestListenHandoff(t *testing.T) {
+	t.Setenv("REFRESH_LISTEN_HELPER", "1")
+	t.Run("cmd", func(t *testing.T) {
+		testListenHandoff(t, Execute{Cmd: "'" + os.Args[0] + "' -test.run='^TestListenHelper$'"})
+	})
+	t.Run("env", func(t *testing.T) {
+		testListenHandoff(t, Execute{Cmd: "REFRESH_LISTEN_PREFIX=1 '" + os.Args[0] + "' -test.run='^TestListenHelper$'"})
+	})
+	t.Run("args", func(t *testing.T) {
+		testListenHandoff(t, Execute{Args: []string{os.Args[0], "-test.run=^TestListenHelper$"}})
+	})
+}
+
+func testListenHandoff(t *testing.T, spec Execute) {
+	probe, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := probe.Addr().String()
+	probe.Close()
+
+	pm := NewProcessManager()
+	spec.Name, spec.Type, spec.Listen = "app", Primary, []string{addr}
+	err = pm.AddProcessSpec(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer pm.Shutdown()
+
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	get := func() (string, error) {
+		resp, err := client.Get("http://" + addr)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+	first, err := get()
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	// Hammer the port while the primary restarts; nothing may be refused.
+	var failures atomic.Int32
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if _, err := get(); err != nil {
+				t.Logf("request during restart: %v", err)
+				failures.Add(1)
+			}
+		}
+	}()
+	if err := pm.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	second, err := get()
+	close(stop)
+	<-done
+	if err != nil {
+		t.Fatalf("request after reload: %v", err)
+	}
+	if second == first {
+		t.Errorf("served by pid %s before and after the reload, want a new instance", first)
+	}
+	if n := failures.Load(); n > 0 {
+		t.Errorf("%d requests failed while the primary restarted", n)
+	}
+
+	pm.Shutdown()
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		conn.Close()
+		t.Error("socket still accepting after Shutdown")
+	}
+}
@@ -0,0 +1,156 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+// LogLine is one line of process output retained in a process's ring buffer.
+type LogLine struct {
+	// Name is the process the line came from (see ProcessInfo.Name).
+	Name string
+	// Stream is "stdout" or "stderr".
+	Stream string
+	// Text is the line without its trailing newline.
+	Text string
+	// Time is when the line was written.
+	Time time.Time
+	// Seq orders lines across both streams of a process; it increases by one per
+	// line for the lifetime of the process manager.
+	Seq uint64
+}
+
+// followBuffer is the channel capacity given to each FollowLogs subscriber. A
+// subscriber that falls further behind than this drops lines rather than stalling
+// the process writing them.
+const followBuffer = 256
+
+// logRing keeps the last size lines of each of a process's streams and fans new
+// lines out to followers. It is shared by every run of the process, so history
+// survives a primary restart. All fields are guarded by mu; writes arrive from
+// exec's copying goroutines while readers are consumer goroutines.
+type logRing struct {
+	mu        sync.Mutex
+	name      string
+	size      int
+	seq       uint64
+	streams   map[string][]LogLine // per-stream ring, oldest first
+	followers map[chan LogLine]struct{}
+}
+
+func newLogRing(name string, size int) *logRing {
+	return &logRing{
+		name:      name,
+		size:      size,
+		streams:   make(map[string][]LogLine, 2),
+		followers: make(map[chan LogLine]struct{}),
+	}
+}
+
+// add appends a completed line to its stream's ring, evicting the oldest line
+// once the ring is full, and offers it to every follower without blocking.
+func (r *logRing) add(stream, text string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	line := LogLine{Name: r.name, Stream: stream, Text: text, Time: time.Now(), Seq: r.seq}
+	ring := append(r.streams[stream], line)
+	if len(ring) > r.size {
+		ring = ring[len(ring)-r.size:]
+	}
+	r.streams[stream] = ring
+	for ch := range r.followers {
+		select {
+		case ch <- line:
+		default: // follower is behind; drop rather than block the process
+		}
+	}
+}
+
+// tail returns up to n of the most recent lines across both streams, oldest
+// first. n <= 0 returns everything retained.
+func (r *logRing) tail(n int) []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []LogLine
+	for _, ring := range r.streams {
+		all = append(all, ring...)
+	}
+	sortBySeq(all)
+	if n > 0 && len(all) > n {
+		all = all[len(all)-n:]
+	}
+	return all
+}
+
+// follow registers a follower that receives every line written from now on
+// until ctx is cancelled, at which point the channel is closed.
+func (r *logRing) follow(ctx context.Context) <-chan LogLine {
+	ch := make(chan LogLine, followBuffer)
+	r.mu.Lock()
+	r.followers[ch] = struct{}{}
+	r.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		r.mu.Lock()
+		delete(r.followers, ch)
+		r.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// sortBySeq orders lines by sequence. The two per-stream rings are each already
+// ordered, so an insertion sort over their concatenation stays cheap.
+func sortBySeq(lines []LogLine) {
+	for i := 1; i < len(lines); i++ {
+		for j := i; j > 0 && lines[j].Seq < lines[j-1].Seq; j-- {
+			lines[j], lines[j-1] = lines[j-1], lines[j]
+		}
+	}
+}
+
+// lineWriter splits one stream of a single run into lines for a logRing. A
+// partial trailing line is held until the next newline or flush.
+type lineWriter struct {
+	ring    *logRing
+	stream  string
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.ring.add(w.stream, string(bytes.TrimSuffix(w.partial[:i], []byte("\r"))))
+		w.partial = w.partial[i+1:]
+	}
+	return len(b), nil
+}
+
+// flush records any unterminated final line; called once the run has exited and
+// its output has been fully copied.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.partial) > 0 {
+		w.ring.add(w.stream, string(w.partial))
+		w.partial = nil
+	}
+}
+
+// rename updates the process name stamped on future lines after the process is
+// renamed by a runtime update.
+func (r *logRing) rename(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.name = name
+}
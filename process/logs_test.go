@@ -0,0 +1,96 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLogRingEvictsOldestPerStream(t *testing.T) {
+	r := newLogRing("app", 2)
+	w := &lineWriter{ring: r, stream: "stdout"}
+	if _, err := w.Write([]byte("one\ntwo\nthree\n")); err != nil {
+		t.Fatal(err)
+	}
+	r.add("stderr", "oops")
+
+	got := r.tail(0)
+	want := []string{"two", "three", "oops"}
+	if len(got) != len(want) {
+		t.Fatalf("tail = %+v, want %v", got, want)
+	}
+	for i, line := range got {
+		if line.Text != want[i] {
+			t.Errorf("line %d = %q, want %q", i, line.Text, want[i])
+		}
+	}
+	if last := r.tail(1); len(last) != 1 || last[0].Stream != "stderr" {
+		t.Errorf("tail(1) = %+v, want the stderr line", last)
+	}
+}
+
+func TestLineWriterHoldsPartialUntilFlush(t *testing.T) {
+	r := newLogRing("app", 10)
+	w := &lineWriter{ring: r, stream: "stdout"}
+	_, _ = w.Write([]byte("hel"))
+	_, _ = w.Write([]byte("lo\r\nwor"))
+	if got := r.tail(0); len(got) != 1 || got[0].Text != "hello" {
+		t.Fatalf("tail = %+v, want just the completed line", got)
+	}
+	w.flush()
+	if got := r.tail(0); len(got) != 2 || got[1].Text != "wor" {
+		t.Errorf("tail after flush = %+v, want the partial line recorded", got)
+	}
+}
+
+// TestLogsBufferedAcrossRuns verifies the manager keeps each process's output
+// and that a follower sees lines written after it subscribed.
+func TestLogsBufferedAcrossRuns(t *testing.T) {
+	pm := NewProcessManager()
+	pm.LogLines = 10
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.AddProcessSpec(Execute{Name: "build", Cmd: "echo built && echo warn 1>&2", Type: Blocking}); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.AddProcessSpec(Execute{Name: "app", Cmd: "true", Type: Primary}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer pm.Shutdown()
+
+	if got := pm.Logs("build", 0); len(got) != 2 {
+		t.Fatalf("Logs(build) = %+v, want 2 lines", got)
+	}
+
+	lines, err := pm.FollowLogs(ctx, "build")
+	if err != nil {
+		t.Fatalf("FollowLogs: %v", err)
+	}
+	if err := pm.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	select {
+	case line := <-lines:
+		if line.Name != "build" || line.Text == "" {
+			t.Errorf("followed line = %+v, want a build line", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("follower received nothing after reload")
+	}
+	if got := pm.Logs("build", 0); len(got) != 4 {
+		t.Errorf("Logs(build) after reload = %d lines, want 4 (history kept across runs)", len(got))
+	}
+
+	if _, err := pm.FollowLogs(ctx, "missing"); err == nil {
+		t.Error("FollowLogs on an unknown process should error")
+	}
+}
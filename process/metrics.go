@@ -0,0 +1,154 @@
+package process
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Metrics is a sample of the resources a running process uses, summed over
+// its whole process group, so a shell and the command it runs (and anything
+// they start) count together.
+type Metrics struct {
+	// RSS is the resident memory in bytes.
+	RSS uint64
+	// CPU is the CPU used since the previous sample, in percent of one core; it
+	// exceeds 100 for a process busy on several cores. The first sample of an
+	// instance averages over its whole lifetime instead.
+	CPU float64
+	// Threads is the number of threads.
+	Threads int
+	// FDs is the number of open file descriptors.
+	FDs int
+	// Processes is the number of processes in the group.
+	Processes int
+	// SampledAt is when the sample was taken; zero when there is none.
+	SampledAt time.Time
+}
+
+// MetricsFunc receives a process's snapshot each time its Metrics are sampled.
+// It is called from the sampling goroutine and must not block.
+type MetricsFunc func(ProcessInfo)
+
+// groupUsage is the raw usage of a process group at one moment.
+type groupUsage struct {
+	rss       uint64
+	cpu       time.Duration
+	threads   int
+	fds       int
+	processes int
+}
+
+// cpuSample is the cumulative CPU time of an instance when it was last
+// sampled, from which the next sample's CPU percentage is computed.
+type cpuSample struct {
+	pid int
+	cpu time.Duration
+	at  time.Time
+}
+
+// startMetrics starts sampling the running processes every MetricsInterval,
+// unless it is unset, metrics are unsupported, or sampling already runs.
+// Shutdown stops it.
+func (pm *ProcessManager) startMetrics(ctx context.Context) {
+	if pm.MetricsInterval <= 0 || pm.stopMetrics != nil {
+		return
+	}
+	if !metricsSupported {
+		slog.Warn("process metrics are not supported on this platform")
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	pm.stopMetrics = func() {
+		cancel()
+		<-done
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(pm.MetricsInterval)
+		defer ticker.Stop()
+		prev := make(map[*Process]cpuSample)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				pm.sampleMetrics(prev)
+			}
+		}
+	}()
+}
+
+// sampleMetrics samples every running process once, with a single scan of
+// their process groups, records the result for Snapshot and reports it to
+// OnMetrics. prev carries each instance's CPU time from one sample to the
+// next.
+func (pm *ProcessManager) sampleMetrics(prev map[*Process]cpuSample) {
+	type target struct {
+		p         *Process
+		pid       int
+		startedAt time.Time
+	}
+	var targets []target
+	pm.mu.RLock()
+	for _, p := range pm.Processes {
+		if p.state == StateRunning && p.pid != 0 {
+			targets = append(targets, target{p, p.pid, p.startedAt})
+		}
+	}
+	pm.mu.RUnlock()
+
+	pgids := make([]int, len(targets))
+	for i, t := range targets {
+		pgids[i] = t.pid
+	}
+	usages, err := sampleGroups(pgids, true)
+	if err != nil {
+		slog.Debug("sampling process metrics", "err", err)
+		return
+	}
+	seen := make(map[*Process]bool, len(targets))
+	for _, t := range targets {
+		usage, ok := usages[t.pid]
+		if !ok {
+			continue
+		}
+		now := time.Now()
+		seen[t.p] = true
+		last, ok := prev[t.p]
+		if !ok || last.pid != t.pid {
+			last = cpuSample{pid: t.pid, at: t.startedAt}
+		}
+		prev[t.p] = cpuSample{pid: t.pid, cpu: usage.cpu, at: now}
+		m := Metrics{
+			RSS:       usage.rss,
+			Threads:   usage.threads,
+			FDs:       usage.fds,
+			Processes: usage.processes,
+			SampledAt: now,
+		}
+		// The group's CPU time drops when a busy member exits; report that as idle.
+		if elapsed := now.Sub(last.at); elapsed > 0 && usage.cpu > last.cpu {
+			m.CPU = 100 * float64(usage.cpu-last.cpu) / float64(elapsed)
+		}
+
+		pm.mu.Lock()
+		if t.p.state != StateRunning || t.p.pid != t.pid {
+			pm.mu.Unlock()
+			continue
+		}
+		t.p.metrics = m
+		info := t.p.info()
+		hook := pm.OnMetrics
+		pm.mu.Unlock()
+		if hook != nil {
+			hook(info)
+		}
+	}
+	for p := range prev {
+		if !seen[p] {
+			delete(prev, p)
+		}
+	}
+}
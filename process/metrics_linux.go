@@ -0,0 +1,87 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const metricsSupported = true
+
+// clockTick is the unit of the CPU times in /proc/<pid>/stat (USER_HZ, 100 on
+// every Linux architecture Go supports).
+const clockTick = 10 * time.Millisecond
+
+// sampleGroups sums the usage of every process in each of the process groups
+// pgids, from a single scan of /proc. Groups with no process left are missing
+// from the result. Open file descriptors are counted only when fds is set.
+func sampleGroups(pgids []int, fds bool) (map[int]groupUsage, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	pageSize := uint64(os.Getpagesize())
+	usages := make(map[int]groupUsage, len(pgids))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Processes may exit between listing /proc and reading them.
+		stat, err := readStat(pid)
+		if err != nil || !slices.Contains(pgids, stat.pgrp) {
+			continue
+		}
+		usage := usages[stat.pgrp]
+		usage.processes++
+		usage.rss += stat.rss * pageSize
+		usage.cpu += time.Duration(stat.utime+stat.stime) * clockTick
+		usage.threads += stat.threads
+		if fds {
+			if entries, err := os.ReadDir(fmt.Sprintf("/proc/%d/fd", pid)); err == nil {
+				usage.fds += len(entries)
+			}
+		}
+		usages[stat.pgrp] = usage
+	}
+	return usages, nil
+}
+
+// procStat holds the fields of /proc/<pid>/stat that metrics use.
+type procStat struct {
+	pgrp         int
+	utime, stime uint64
+	threads      int
+	rss          uint64
+}
+
+// readStat parses /proc/<pid>/stat (see proc(5)).
+func readStat(pid int) (procStat, error) {
+	var st procStat
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return st, err
+	}
+	// The command name in parentheses may itself contain spaces or
+	// parentheses, so the fields are counted from the last ')'.
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return st, errors.New("malformed stat")
+	}
+	// fields[0] is field 3 of proc(5), the state.
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 22 {
+		return st, errors.New("malformed stat")
+	}
+	var errs [5]error
+	st.pgrp, errs[0] = strconv.Atoi(fields[2])
+	st.utime, errs[1] = strconv.ParseUint(fields[11], 10, 64)
+	st.stime, errs[2] = strconv.ParseUint(fields[12], 10, 64)
+	st.threads, errs[3] = strconv.Atoi(fields[17])
+	st.rss, errs[4] = strconv.ParseUint(fields[21], 10, 64)
+	return st, errors.Join(errs[:]...)
+}
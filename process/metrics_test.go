@@ -0,0 +1,98 @@
+//go:build linux
+
+package process
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMetrics checks samples cover a process's whole group, measure its CPU
+// use, reach OnMetrics and are cleared once the process stops.
+func TestMetrics(t *testing.T) {
+	var mu sync.Mutex
+	sampled := make(map[string]int)
+	pm := NewProcessManager()
+	pm.MetricsInterval = 50 * time.Millisecond
+	pm.OnMetrics = func(info ProcessInfo) {
+		mu.Lock()
+		sampled[info.Name]++
+		mu.Unlock()
+	}
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	specs := []Execute{
+		{Name: "spin", Cmd: "while :; do :; done", Type: Background},
+		{Name: "app", Cmd: "sleep 30 & exec sleep 30", Type: Primary},
+	}
+	for _, spec := range specs {
+		if err := pm.AddProcessSpec(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	// Let a few samples measure the spinning process's CPU use.
+	time.Sleep(300 * time.Millisecond)
+	snapshot := pm.Snapshot()
+	spin, app := snapshot[0].Metrics, snapshot[1].Metrics
+	if spin.SampledAt.IsZero() || app.SampledAt.IsZero() {
+		t.Fatalf("metrics not sampled: spin %+v, app %+v", spin, app)
+	}
+	if spin.CPU < 20 {
+		t.Errorf("spin CPU = %.1f%%, want it busy", spin.CPU)
+	}
+	if app.Processes != 2 || app.Threads < 2 || app.RSS == 0 || app.FDs == 0 {
+		t.Errorf("app metrics = %+v, want both sleeps counted", app)
+	}
+	mu.Lock()
+	if sampled["spin"] == 0 || sampled["app"] == 0 {
+		t.Errorf("OnMetrics calls = %v, want both processes", sampled)
+	}
+	mu.Unlock()
+
+	pm.Shutdown()
+	for _, info := range pm.Snapshot() {
+		if !info.Metrics.SampledAt.IsZero() {
+			t.Errorf("%s still has metrics after shutdown: %+v", info.Name, info.Metrics)
+		}
+	}
+}
+
+// TestSampleGroups checks one scan reports each group's own processes and
+// leaves out groups with none.
+func TestSampleGroups(t *testing.T) {
+	var pgids []int
+	for _, argv := range [][]string{{"sleep", "5"}, {"/bin/sh", "-c", "sleep 5 & sleep 5 & wait"}} {
+		cmd := exec.Command(argv[0], argv[1:]...)
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			killProcessTree(cmd)
+			cmd.Wait()
+		})
+		pgids = append(pgids, cmd.Process.Pid)
+	}
+	var usages map[int]groupUsage
+	ok := waitFor(func() bool {
+		var err error
+		usages, err = sampleGroups(append(pgids, 1<<30), false)
+		return err == nil && usages[pgids[0]].processes == 1 && usages[pgids[1]].processes == 3
+	})
+	if !ok {
+		t.Fatalf("usages = %+v, want 1 process in group %d and 3 in group %d", usages, pgids[0], pgids[1])
+	}
+	if _, found := usages[1<<30]; found {
+		t.Error("reported a group that has no process")
+	}
+}
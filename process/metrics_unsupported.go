@@ -0,0 +1,12 @@
+//go:build !linux
+
+package process
+
+import "errors"
+
+const metricsSupported = false
+
+// sampleGroups is unsupported outside Linux.
+func sampleGroups(pgids []int, fds bool) (map[int]groupUsage, error) {
+	return nil, errors.New("process metrics are only supported on Linux")
+}
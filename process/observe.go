@@ -22,6 +22,13 @@ const (
 	// StateKilled means the process was terminated by refresh (a reload restarting
 	// a primary, or shutdown), rather than exiting on its own.
 	StateKilled ProcessState = "killed"
+	// StateSkipped means a blocking or once step was not run because its inputs
+	// and outputs are unchanged since its last successful run (see
+	// Execute.Inputs).
+	StateSkipped ProcessState = "skipped"
+	// StateRemoved is reported once, when a process is removed from the manager
+	// at runtime; it no longer appears in snapshots afterwards.
+	StateRemoved ProcessState = "removed"
 )
 
 // ProcessInfo is an immutable snapshot of a process's identity and current
@@ -44,6 +51,10 @@ type ProcessInfo struct {
 	// ExitCode is the exit code of the last completed run, or -1 when the process
 	// was killed or has not yet exited.
 	ExitCode int
+	// Metrics is the latest resource usage sample of the running instance, when
+	// ProcessManager.MetricsInterval is set; zero until the first sample and
+	// once the instance ends.
+	Metrics Metrics
 }
 
 // ProcessEvent is delivered to an OnEvent hook every time a process changes
@@ -54,6 +65,13 @@ type ProcessEvent struct {
 	Time time.Time
 	// Err is set when a process failed or could not be started; nil otherwise.
 	Err error
+	// Diagnostics holds the structured problems parsed from a failed step's
+	// output by its configured parsers (see Execute.Diagnostics). Set only on
+	// StateFailed events.
+	Diagnostics []Diagnostic
+	// Reason is set on StateFailed events of a process that a resource limit
+	// ended (see Execute.Limits); Err is then a *LimitError.
+	Reason FailureReason
 }
 
 // OutputFunc resolves the writer that a process's stdout or stream output is
@@ -73,17 +91,23 @@ const noExitYet = -1
 // info builds a snapshot from a process's current fields. Callers must hold
 // pm.mu (read or write) so the runtime fields are read consistently.
 func (p *Process) info() ProcessInfo {
-	name := p.Name
-	if name == "" {
-		name = p.Exec
-	}
 	return ProcessInfo{
-		Name:      name,
+		Name:      p.displayName(),
 		Exec:      p.Exec,
 		Type:      p.Type,
 		State:     p.state,
 		PID:       p.pid,
 		StartedAt: p.startedAt,
 		ExitCode:  p.exitCode,
+		Metrics:   p.metrics,
+	}
+}
+
+// displayName is the process's stable identifier: its Name, or the command
+// string when no name was configured.
+func (p *Process) displayName() string {
+	if p.Name == "" {
+		return p.Exec
 	}
+	return p.Name
 }
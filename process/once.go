@@ -0,0 +1,79 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// StepResult is the outcome of one step of a RunOnce pass.
+type StepResult struct {
+	Name string
+	Type ExecuteType
+	// State is exited or failed for a step that ran to completion, skipped for
+	// an incremental step whose inputs were unchanged, running for a started
+	// background process, killed when the pass was cancelled mid-step, and
+	// pending for a step that never ran: a skipped primary, or any step after
+	// the one that failed.
+	State ProcessState
+	// ExitCode is the step's exit code, or -1 when it has not exited.
+	ExitCode int
+	// Duration is how long the step ran; for a background process, how long it
+	// took to start.
+	Duration time.Duration
+}
+
+// RunOnce runs every configured step a single time, as a CI job would.
+// Background processes are started and left running for the caller to
+// Shutdown, once and blocking steps run to completion, and the primary is
+// skipped — or, with runPrimary, run to completion like a blocking step.
+// Periodic tasks are skipped. The pass stops at the first failing step and
+// returns its *StepError. The results cover every step in configured order,
+// including those that did not run.
+func (pm *ProcessManager) RunOnce(ctx context.Context, runPrimary bool) ([]StepResult, error) {
+	if len(pm.Processes) == 0 {
+		return nil, errors.New("no processes configured")
+	}
+	results := make([]StepResult, 0, len(pm.Processes))
+	var failed error
+	for _, p := range pm.Processes {
+		// Markers used by the ExecList config form; no-ops in the struct form.
+		if p.Exec == KILL_EXEC || p.Exec == REFRESH_EXEC {
+			continue
+		}
+		res := StepResult{Name: p.displayName(), Type: p.Type, State: StatePending, ExitCode: noExitYet}
+		if failed != nil || p.Type == Periodic || (p.Type == Primary && !runPrimary) {
+			results = append(results, res)
+			continue
+		}
+
+		start := time.Now()
+		var err error
+		if p.Type == Background {
+			err = pm.startAsync(ctx, p)
+		} else {
+			err = pm.runStep(ctx, p)
+		}
+		res.Duration = time.Since(start)
+		pm.mu.RLock()
+		res.State, res.ExitCode = p.state, p.exitCode
+		pm.mu.RUnlock()
+		results = append(results, res)
+
+		switch {
+		case ctx.Err() != nil:
+			failed = ctx.Err()
+		case err != nil:
+			slog.Error("step failed", "exec", p.Exec, "err", err)
+			pm.setCycleDiagnostics(p)
+			failed = pm.stepError(p, err)
+		case !pm.delayNext(ctx, p):
+			failed = ctx.Err()
+		}
+	}
+	if failed == nil {
+		pm.setCycleDiagnostics(nil)
+	}
+	return results, failed
+}
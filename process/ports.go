@@ -0,0 +1,61 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+)
+
+// defaultPortTimeout bounds the wait for Execute.Ports when PortTimeout is
+// unset.
+const defaultPortTimeout = 5 * time.Second
+
+// waitPorts waits until every port in the process's Ports can be bound again,
+// failing when one is still taken after the timeout. The error names the
+// process holding it where the platform can tell.
+func (pm *ProcessManager) waitPorts(ctx context.Context, p *Process) error {
+	if len(p.spec.Ports) == 0 {
+		return nil
+	}
+	timeout := defaultPortTimeout
+	if p.spec.PortTimeout > 0 {
+		timeout = time.Duration(p.spec.PortTimeout) * time.Millisecond
+	}
+	deadline := time.Now().Add(timeout)
+	for _, port := range p.spec.Ports {
+		for waited := false; ; waited = true {
+			err := portFree(port)
+			if err == nil {
+				break
+			}
+			if !waited {
+				slog.Debug("waiting for port to be released", "exec", p.Exec, "port", port)
+			}
+			if time.Now().After(deadline) {
+				if pid, name := portHolder(port); pid != 0 {
+					return fmt.Errorf("port %d still in use after %s, held by pid %d (%s)", port, timeout, pid, name)
+				}
+				return fmt.Errorf("port %d still in use after %s: %w", port, timeout, err)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// portFree reports, as a nil error, whether a TCP listener could be opened on
+// port on all interfaces.
+func portFree(port int) error {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	return ln.Close()
+}
@@ -0,0 +1,56 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// portHolder finds a process with a socket on local TCP port port by matching
+// the socket inodes listed in /proc/net/tcp and /proc/net/tcp6 against the
+// descriptors in /proc/<pid>/fd. It returns 0 when there is none or /proc does
+// not show it, e.g. for another user's process.
+func portHolder(port int) (pid int, name string) {
+	sockets := make(map[string]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+		for line := range strings.Lines(string(data)) {
+			// sl local_address rem_address st ... uid timeout inode
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[9] == "0" {
+				continue
+			}
+			_, hexPort, ok := strings.Cut(fields[1], ":")
+			if n, err := strconv.ParseUint(hexPort, 16, 16); !ok || err != nil || int(n) != port {
+				continue
+			}
+			sockets["socket:["+fields[9]+"]"] = true
+		}
+	}
+	if len(sockets) == 0 {
+		return 0, ""
+	}
+	procs, _ := os.ReadDir("/proc")
+	for _, entry := range procs {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if link, err := os.Readlink(filepath.Join(fdDir, fd.Name())); err == nil && sockets[link] {
+				comm, _ := os.ReadFile(filepath.Join("/proc", entry.Name(), "comm"))
+				return pid, strings.TrimSpace(string(comm))
+			}
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,67 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWaitPorts checks the primary starts only once its port is released, and
+// that a port held past the timeout fails the step naming the holder.
+func TestWaitPorts(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	err = pm.AddProcessSpec(Execute{Cmd: "sleep 30", Type: Primary, Ports: []int{port}, PortTimeout: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+
+	released := make(chan time.Time, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		released <- time.Now()
+		ln.Close()
+	}()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	info := pm.Snapshot()[0]
+	if info.State != StateRunning || info.StartedAt.Before(<-released) {
+		t.Errorf("primary %+v started before the port was released", info)
+	}
+
+	held, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Close()
+	pm.Processes[0].spec.PortTimeout = 100
+	err = pm.Reload(ctx)
+	if err == nil || !strings.Contains(err.Error(), "port "+strconv.Itoa(port)+" still in use") {
+		t.Fatalf("Reload with the port held = %v", err)
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("error %q does not name the holding pid %d", err, os.Getpid())
+	}
+	if info := pm.Snapshot()[0]; info.State != StateFailed {
+		t.Errorf("primary state = %s, want failed", info.State)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package process
+
+// portHolder cannot identify the process holding a port outside Linux.
+func portHolder(port int) (pid int, name string) {
+	return 0, ""
+}
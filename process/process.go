@@ -3,12 +3,15 @@ package process
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -29,6 +32,16 @@ type Process struct {
 	cancel context.CancelFunc
 	done   chan struct{}
 
+	// spec is the Execute the process was built from, kept so a runtime update
+	// or config reload can tell whether it changed.
+	spec Execute
+	// parsers extract diagnostics from this step's output when it fails.
+	parsers []DiagnosticParser
+
+	// logs is the process's output ring buffer, created on first run when
+	// ProcessManager.LogLines is set. Guarded by ProcessManager.mu.
+	logs *logRing
+
 	// Runtime state observable through ProcessInfo snapshots. Guarded by
 	// ProcessManager.mu because the per-process wait goroutine writes the exit
 	// state while a consumer goroutine may read a snapshot concurrently.
@@ -36,15 +49,28 @@ type Process struct {
 	pid       int
 	startedAt time.Time
 	exitCode  int
+	// metrics is the latest sample of the running instance; zero until one is
+	// taken.
+	metrics Metrics
+	// diagnostics parsed from the last failed run; cleared when it next starts.
+	diagnostics []Diagnostic
+	// run numbers the instances startAsync launches, so the wait goroutine of an
+	// instance replaced by a start-first restart leaves the new one's state be.
+	run int
+	// schedule is when a periodic task runs; nil for other types.
+	schedule Schedule
 }
 
 // ProcessManager supervises the configured processes.
 //
-// Lifecycle methods (Start, Reload, Shutdown) are driven from a single
+// Lifecycle methods (Start, Reload, Shutdown, and the runtime Add, Remove,
+// Update and per-process Start/Stop/RestartProcess) are driven from a single
 // goroutine — the engine's supervisor loop guarantees this — so the process
-// handles (cmd/cancel/done) need no locking. The observable runtime state
-// (state/pid/startedAt/exitCode), however, is also written by each process's
-// wait goroutine and read by consumers via Snapshot, so it is guarded by mu.
+// handles (cmd/cancel/done) need no locking. The Processes slice itself is
+// also written under mu when it changes at runtime, so Snapshot never sees it
+// mid-update. The observable runtime state (state/pid/startedAt/exitCode),
+// however, is also written by each process's wait goroutine and read by
+// consumers via Snapshot, so it is guarded by mu.
 type ProcessManager struct {
 	Processes []*Process
 	RootDir   string
@@ -55,8 +81,39 @@ type ProcessManager struct {
 	Output OutputFunc
 	// OnEvent, when set, receives a ProcessEvent on every state transition.
 	OnEvent EventFunc
+	// LogLines, when > 0, keeps the last LogLines lines of each process's stdout
+	// and stderr in memory for Logs and FollowLogs. Output is still delivered to
+	// the Output writer (or the terminal) as usual. Once processes run, change it
+	// with SetLogLines.
+	LogLines int
+	// MetricsInterval, when > 0, samples the CPU and memory use of each running
+	// process's group at this interval into ProcessInfo.Metrics. Linux only.
+	MetricsInterval time.Duration
+	// OnMetrics, when set, receives a process's snapshot after each sample.
+	OnMetrics MetricsFunc
+
+	// listeners are the sockets bound for processes' Listen addresses, by
+	// address. Owned by the supervising goroutine.
+	listeners map[string]*net.TCPListener
+	// cache holds the fingerprints of incremental steps, loaded on first use.
+	// Owned by the supervising goroutine.
+	cache *stepCache
+	// paused makes periodic tasks skip their runs; see SetPaused.
+	paused atomic.Bool
+	// changes counts the reloads and updates in flight, and settled is closed
+	// when the last of them finishes; periodic tasks hold the runs that fall
+	// due meanwhile until then rather than overlap them. Guarded by changeMu.
+	changeMu sync.Mutex
+	changes  int
+	settled  chan struct{}
+	// stopMetrics stops the metrics sampler and waits for it; nil when it is
+	// not running. Owned by the supervising goroutine.
+	stopMetrics func()
 
 	mu sync.RWMutex
+	// diagnostics from the step that failed the last cycle; nil after a cycle
+	// that succeeds. Guarded by mu.
+	diagnostics []Diagnostic
 }
 
 func NewProcessManager() *ProcessManager {
@@ -77,20 +134,58 @@ func (pm *ProcessManager) AddProcessWithDelay(exec, typing, dir string, delay in
 // AddProcessSpec appends a process from a full Execute spec, preserving its Name
 // (used as the per-process identifier in snapshots and events).
 func (pm *ProcessManager) AddProcessSpec(spec Execute) error {
-	execType, err := stringToExecuteType(string(spec.Type))
+	p, err := newProcess(spec)
 	if err != nil {
 		return err
 	}
-	pm.Processes = append(pm.Processes, &Process{
+	pm.mu.Lock()
+	pm.Processes = append(pm.Processes, p)
+	pm.mu.Unlock()
+	return nil
+}
+
+// newProcess validates an Execute spec and builds the pending process for it.
+func newProcess(spec Execute) (*Process, error) {
+	execType, err := stringToExecuteType(string(spec.Type))
+	if err != nil {
+		return nil, err
+	}
+	if spec.Cmd != "" && len(spec.Args) > 0 {
+		return nil, fmt.Errorf("execute %q sets both cmd and args", spec.Command())
+	}
+	if !spec.RestartStrategy.valid() {
+		return nil, fmt.Errorf("restart strategy of %q is invalid", spec.RestartStrategy)
+	}
+	if err := spec.Limits.Validate(); err != nil {
+		return nil, fmt.Errorf("execute %q: limits: %w", spec.Command(), err)
+	}
+	parsers := make([]DiagnosticParser, 0, len(spec.Diagnostics)+len(spec.Parsers))
+	for _, name := range spec.Diagnostics {
+		parser, err := LookupParser(name)
+		if err != nil {
+			return nil, err
+		}
+		parsers = append(parsers, parser)
+	}
+	parsers = append(parsers, spec.Parsers...)
+	var schedule Schedule
+	if execType == Periodic {
+		if schedule, err = ParseSchedule(spec.Schedule); err != nil {
+			return nil, err
+		}
+	}
+	return &Process{
 		Name:     spec.Name,
-		Exec:     spec.Cmd,
+		Exec:     spec.Command(),
 		Type:     execType,
 		Dir:      spec.ChangeDir,
 		Delay:    spec.DelayNext,
+		spec:     spec,
+		parsers:  parsers,
+		schedule: schedule,
 		state:    StatePending,
 		exitCode: noExitYet,
-	})
-	return nil
+	}, nil
 }
 
 // Snapshot returns the current state of every configured process, in order. It
@@ -111,33 +206,166 @@ func (pm *ProcessManager) Snapshot() []ProcessInfo {
 // into Snapshot without deadlocking). pid and exitCode are applied only when
 // non-zero / not the keep sentinel, so callers can update state alone.
 func (pm *ProcessManager) transition(p *Process, state ProcessState, pid int, exitCode int, err error) {
+	pm.runTransition(p, 0, state, pid, exitCode, err)
+}
+
+// runTransition is transition for one instance of a long-lived process, run
+// being the number startAsync gave it; it is dropped once a later instance has
+// replaced that one. A run of 0 always applies.
+func (pm *ProcessManager) runTransition(p *Process, run int, state ProcessState, pid int, exitCode int, err error) {
 	pm.mu.Lock()
+	if run != 0 && run != p.run {
+		pm.mu.Unlock()
+		return
+	}
 	p.state = state
 	if pid != 0 {
 		p.pid = pid
 	}
 	if state == StateRunning {
 		p.startedAt = time.Now()
+		p.diagnostics = nil
 	}
+	// Every transition starts or ends an instance, whose samples don't carry
+	// over.
+	p.metrics = Metrics{}
 	if exitCode != keepExitCode {
 		p.exitCode = exitCode
 	}
 	if state == StateExited || state == StateFailed || state == StateKilled {
 		p.pid = 0
 	}
-	info := p.info()
+	ev := ProcessEvent{Info: p.info(), Time: time.Now(), Err: err}
+	if state == StateFailed {
+		ev.Diagnostics = p.diagnostics
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			ev.Reason = limitErr.Reason
+		}
+	}
 	hook := pm.OnEvent
 	pm.mu.Unlock()
 
 	if hook != nil {
-		hook(ProcessEvent{Info: info, Time: time.Now(), Err: err})
+		hook(ev)
+	}
+}
+
+// Diagnostics returns the diagnostics parsed from the step that failed the most
+// recent cycle, or nil if that cycle succeeded or its failing step has no
+// parsers configured. Safe to call from any goroutine.
+func (pm *ProcessManager) Diagnostics() []Diagnostic {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return append([]Diagnostic(nil), pm.diagnostics...)
+}
+
+// setCycleDiagnostics records the diagnostics of the step that failed the
+// current cycle (nil clears them after a successful cycle).
+func (pm *ProcessManager) setCycleDiagnostics(p *Process) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if p == nil {
+		pm.diagnostics = nil
+		return
 	}
+	pm.diagnostics = p.diagnostics
 }
 
 // keepExitCode is passed to transition to leave the recorded exit code
 // unchanged (used by transitions that aren't process completions).
 const keepExitCode = -2
 
+// wireOutput connects a run's stdout and stderr to their writers and, when
+// LogLines is set, tees each stream into the process's ring buffer. The returned
+// func records any unterminated final line and must be called once the run has
+// exited.
+func (pm *ProcessManager) wireOutput(p *Process, cmd *exec.Cmd) (flush func()) {
+	cmd.Stdout = pm.stdio(p, "stdout", os.Stdout)
+	cmd.Stderr = pm.stdio(p, "stderr", os.Stderr)
+	ring := pm.ring(p)
+	if ring == nil {
+		return func() {}
+	}
+	stdout := &lineWriter{ring: ring, stream: "stdout"}
+	stderr := &lineWriter{ring: ring, stream: "stderr"}
+	cmd.Stdout = io.MultiWriter(cmd.Stdout, stdout)
+	cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	return func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// ring returns the process's output ring buffer, creating it on first use, or
+// nil when buffering is disabled.
+func (pm *ProcessManager) ring(p *Process) *logRing {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	if pm.LogLines <= 0 {
+		return nil
+	}
+	if p.logs == nil {
+		p.logs = newLogRing(p.displayName(), pm.LogLines)
+	}
+	return p.logs
+}
+
+// Logs returns up to n of the most recent buffered output lines of the named
+// process across both streams, oldest first; n <= 0 returns every retained line.
+// It returns nil when the process is unknown, has not produced output yet, or
+// LogLines is not set. Safe to call from any goroutine.
+func (pm *ProcessManager) Logs(name string, n int) []LogLine {
+	pm.mu.RLock()
+	p := pm.lookup(name)
+	var ring *logRing
+	if p != nil {
+		ring = p.logs
+	}
+	pm.mu.RUnlock()
+	if ring == nil {
+		return nil
+	}
+	return ring.tail(n)
+}
+
+// FollowLogs streams every line the named process writes from now on, across
+// restarts, until ctx is cancelled and the channel is closed. A follower that
+// falls more than a few hundred lines behind misses lines rather than blocking
+// the process. It errors when LogLines is not set or the process is unknown.
+func (pm *ProcessManager) FollowLogs(ctx context.Context, name string) (<-chan LogLine, error) {
+	pm.mu.RLock()
+	p := pm.lookup(name)
+	pm.mu.RUnlock()
+	if p == nil {
+		return nil, fmt.Errorf("no process named %q", name)
+	}
+	ring := pm.ring(p)
+	if ring == nil {
+		return nil, errors.New("output buffering is disabled")
+	}
+	return ring.follow(ctx), nil
+}
+
+// SetLogLines changes LogLines while processes may be running. Buffers that
+// already exist keep their size; processes that have not produced output yet
+// get the new one. Safe to call from any goroutine.
+func (pm *ProcessManager) SetLogLines(n int) {
+	pm.mu.Lock()
+	pm.LogLines = n
+	pm.mu.Unlock()
+}
+
+// lookup finds a process by its resolved name. Callers must hold pm.mu.
+func (pm *ProcessManager) lookup(name string) *Process {
+	for _, p := range pm.Processes {
+		if p.displayName() == name {
+			return p
+		}
+	}
+	return nil
+}
+
 // stdio resolves the writer for one of a process's streams, honoring the Output
 // hook and falling back to the process's own stdout/stderr.
 func (pm *ProcessManager) stdio(p *Process, stream string, fallback io.Writer) io.Writer {
@@ -199,20 +427,58 @@ func (pm *ProcessManager) resolveDir(dir string) string {
 }
 
 // Start performs the initial pass over all configured processes: background and
-// once processes run only here; blocking and primary processes run every cycle.
+// once processes run only here, and periodic tasks start their schedules here;
+// blocking and primary processes run every cycle.
 func (pm *ProcessManager) Start(ctx context.Context) error {
 	if len(pm.Processes) == 0 {
 		return errors.New("no processes configured")
 	}
+	pm.startMetrics(ctx)
 	return pm.runCycle(ctx, true)
 }
 
 // Reload re-runs blocking steps and restarts the primary process. Background and
 // once processes started during Start are left running.
 func (pm *ProcessManager) Reload(ctx context.Context) error {
+	defer pm.change()()
 	return pm.runCycle(ctx, false)
 }
 
+// change marks a reload or update in flight until the returned func is called.
+func (pm *ProcessManager) change() func() {
+	pm.changeMu.Lock()
+	if pm.changes == 0 {
+		pm.settled = make(chan struct{})
+	}
+	pm.changes++
+	pm.changeMu.Unlock()
+	return func() {
+		pm.changeMu.Lock()
+		if pm.changes--; pm.changes == 0 {
+			close(pm.settled)
+		}
+		pm.changeMu.Unlock()
+	}
+}
+
+// waitSettled waits until no reload or update is in flight, reporting false
+// when ctx ends first.
+func (pm *ProcessManager) waitSettled(ctx context.Context) bool {
+	for {
+		pm.changeMu.Lock()
+		changes, settled := pm.changes, pm.settled
+		pm.changeMu.Unlock()
+		if changes == 0 {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-settled:
+		}
+	}
+}
+
 func (pm *ProcessManager) runCycle(ctx context.Context, firstRun bool) error {
 	for _, p := range pm.Processes {
 		// Markers used by the ExecList config form; no-ops in the struct form.
@@ -226,29 +492,39 @@ func (pm *ProcessManager) runCycle(ctx context.Context, firstRun bool) error {
 			}
 			if err := pm.startAsync(ctx, p); err != nil {
 				slog.Error("starting background process", "exec", p.Exec, "err", err)
-				return err
+				return pm.stepError(p, err)
 			}
 		case Once:
 			if !firstRun {
 				continue
 			}
-			if err := pm.runBlocking(ctx, p); err != nil {
+			if err := pm.runStep(ctx, p); err != nil {
 				slog.Error("once process failed", "exec", p.Exec, "err", err)
-				return err
+				pm.setCycleDiagnostics(p)
+				return pm.stepError(p, err)
 			}
 		case Blocking:
-			if err := pm.runBlocking(ctx, p); err != nil {
+			if err := pm.runStep(ctx, p); err != nil {
 				// On reload a failed blocking step (typically a build error)
 				// aborts the cycle and leaves the current primary running, so a
 				// broken build doesn't take down the last good process.
 				slog.Error("blocking process failed", "exec", p.Exec, "err", err)
-				return err
+				pm.setCycleDiagnostics(p)
+				return pm.stepError(p, err)
+			}
+		case Periodic:
+			// Runs on its own schedule from startup on.
+			if !firstRun {
+				continue
+			}
+			if err := pm.startSchedule(ctx, p); err != nil {
+				return pm.stepError(p, err)
 			}
 		case Primary:
-			pm.stopProcess(p) // kill the previous instance (no-op on first run)
-			if err := pm.startAsync(ctx, p); err != nil {
+			// Replaces the previous instance, if any, per the restart strategy.
+			if err := pm.restartPrimary(ctx, p); err != nil {
 				slog.Error("starting primary process", "exec", p.Exec, "err", err)
-				return err
+				return pm.stepError(p, err)
 			}
 		}
 		// Reached only when the step above actually ran (skipped/aborted steps
@@ -259,6 +535,7 @@ func (pm *ProcessManager) runCycle(ctx context.Context, firstRun bool) error {
 		}
 	}
 	pm.started = true
+	pm.setCycleDiagnostics(nil)
 	return nil
 }
 
@@ -284,28 +561,51 @@ func (pm *ProcessManager) delayNext(ctx context.Context, p *Process) bool {
 // shutdown. The command is started in a fresh process group so the whole tree
 // can be signalled, not just the direct child.
 func (pm *ProcessManager) startAsync(ctx context.Context, p *Process) error {
+	cmd := generateExec(p.spec)
+	if len(p.spec.Listen) > 0 {
+		files, err := pm.listenerFiles(p.spec.Listen)
+		if err == nil {
+			err = inheritListeners(cmd, p.spec, files)
+			// The child holds its own copies once started.
+			defer closeFiles(files)
+		}
+		if err != nil {
+			pm.transition(p, StateFailed, 0, noExitYet, err)
+			return err
+		}
+	}
+	limits, err := pm.applyLimits(p, cmd)
+	if err != nil {
+		pm.transition(p, StateFailed, 0, noExitYet, err)
+		return err
+	}
 	procCtx, cancel := context.WithCancel(ctx)
-	cmd := generateExec(p.Exec)
 	cmd.Dir = pm.resolveDir(p.Dir)
-	cmd.Stdout = pm.stdio(p, "stdout", os.Stdout)
-	cmd.Stderr = pm.stdio(p, "stderr", os.Stderr)
+	flush := pm.wireOutput(p, cmd)
 	setProcessGroup(cmd)
 
 	slog.Debug("starting process", "exec", p.Exec, "dir", cmd.Dir)
-	if err := cmd.Start(); err != nil {
+	if err := limits.start(cmd); err != nil {
 		cancel()
+		limits.release()
 		pm.transition(p, StateFailed, 0, noExitYet, err)
 		return err
 	}
+	limits.started(cmd.Process.Pid)
 
 	done := make(chan struct{})
 	p.cmd = cmd
 	p.cancel = cancel
 	p.done = done
+	pm.mu.Lock()
+	p.run++
+	run := p.run
+	pm.mu.Unlock()
 	pm.transition(p, StateRunning, cmd.Process.Pid, keepExitCode, nil)
 
 	go func() {
 		defer close(done)
+		defer limits.release()
 		waitErr := make(chan error, 1)
 		go func() { waitErr <- cmd.Wait() }()
 		select {
@@ -314,13 +614,16 @@ func (pm *ProcessManager) startAsync(ctx context.Context, p *Process) error {
 				slog.Debug("killing process tree", "exec", p.Exec, "err", err)
 			}
 			<-waitErr // reap the process after the kill
-			pm.transition(p, StateKilled, 0, noExitYet, nil)
+			flush()
+			pm.runTransition(p, run, StateKilled, 0, noExitYet, nil)
 		case err := <-waitErr:
+			flush()
 			if err != nil {
+				err = limits.exited(cmd.ProcessState, err)
 				slog.Debug("process exited", "exec", p.Exec, "err", err)
-				pm.transition(p, StateFailed, 0, exitCodeOf(cmd, err), err)
+				pm.runTransition(p, run, StateFailed, 0, exitCodeOf(cmd, err), err)
 			} else {
-				pm.transition(p, StateExited, 0, 0, nil)
+				pm.runTransition(p, run, StateExited, 0, 0, nil)
 			}
 		}
 	}()
@@ -332,33 +635,53 @@ func (pm *ProcessManager) startAsync(ctx context.Context, p *Process) error {
 // whole tree (not just the direct child, which is all CommandContext would
 // reach) and unblocks the wait.
 func (pm *ProcessManager) runBlocking(ctx context.Context, p *Process) error {
-	cmd := generateExec(p.Exec)
+	cmd := generateExec(p.spec)
 	cmd.Dir = pm.resolveDir(p.Dir)
-	cmd.Stdout = pm.stdio(p, "stdout", os.Stdout)
-	cmd.Stderr = pm.stdio(p, "stderr", os.Stderr)
+	flush := pm.wireOutput(p, cmd)
+	var captured *tailBuffer
+	if len(p.parsers) > 0 {
+		captured = &tailBuffer{}
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, captured)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, captured)
+	}
 	setProcessGroup(cmd)
+	limits, err := pm.applyLimits(p, cmd)
+	if err != nil {
+		pm.transition(p, StateFailed, 0, noExitYet, err)
+		return err
+	}
+	defer limits.release()
 	slog.Debug("running blocking process", "exec", p.Exec, "dir", cmd.Dir)
 
-	if err := cmd.Start(); err != nil {
+	if err := limits.start(cmd); err != nil {
 		pm.transition(p, StateFailed, 0, noExitYet, err)
 		return err
 	}
+	limits.started(cmd.Process.Pid)
 	pm.transition(p, StateRunning, cmd.Process.Pid, keepExitCode, nil)
 
 	waitErr := make(chan error, 1)
 	go func() { waitErr <- cmd.Wait() }()
-	var err error
 	select {
 	case <-ctx.Done():
 		if kerr := killProcessTree(cmd); kerr != nil {
 			slog.Debug("killing blocking process tree", "exec", p.Exec, "err", kerr)
 		}
 		<-waitErr // reap after the kill
+		flush()
 		pm.transition(p, StateKilled, 0, noExitYet, nil)
 		return ctx.Err()
 	case err = <-waitErr:
 	}
+	flush()
 	if err != nil {
+		err = limits.exited(cmd.ProcessState, err)
+		if captured != nil {
+			diags := parseDiagnostics(p.displayName(), p.parsers, captured.String())
+			pm.mu.Lock()
+			p.diagnostics = diags
+			pm.mu.Unlock()
+		}
 		pm.transition(p, StateFailed, 0, exitCodeOf(cmd, err), err)
 		return err
 	}
@@ -366,6 +689,31 @@ func (pm *ProcessManager) runBlocking(ctx context.Context, p *Process) error {
 	return nil
 }
 
+// StepError reports the step that aborted a cycle. It is the error returned by
+// Start and Reload when a process fails or cannot be started, and unwraps to the
+// underlying command error.
+type StepError struct {
+	// Name is the failing process's name (see ProcessInfo.Name).
+	Name string
+	// ExitCode is the step's exit code, or -1 when it never ran to an exit (it
+	// could not be started, was killed, or died from a signal).
+	ExitCode int
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// stepError wraps a step failure with the process's name and recorded exit code.
+func (pm *ProcessManager) stepError(p *Process, err error) error {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	return &StepError{Name: p.displayName(), ExitCode: p.exitCode, Err: err}
+}
+
 // exitCodeOf extracts the process exit code from a completed command, falling
 // back to -1 when the failure wasn't a normal non-zero exit (e.g. a signal).
 func exitCodeOf(cmd *exec.Cmd, err error) int {
@@ -394,7 +742,12 @@ func (pm *ProcessManager) stopProcess(p *Process) {
 // Shutdown terminates every running process and waits for them to exit.
 func (pm *ProcessManager) Shutdown() {
 	slog.Debug("shutting down processes")
+	if pm.stopMetrics != nil {
+		pm.stopMetrics()
+		pm.stopMetrics = nil
+	}
 	for _, p := range pm.Processes {
 		pm.stopProcess(p)
 	}
+	pm.closeListeners()
 }
@@ -4,6 +4,7 @@ package process
 
 import (
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -222,3 +223,90 @@ func TestBlockingFailureAbortsCycle(t *testing.T) {
 	}
 	pm.Shutdown()
 }
+
+// TestFailedStepReportsDiagnostics verifies a failing blocking step's output is
+// parsed into the failed event and the manager's cycle diagnostics, and that a
+// following successful cycle clears them.
+func TestFailedStepReportsDiagnostics(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	var failed []ProcessEvent
+	pm.OnEvent = func(ev ProcessEvent) {
+		if ev.Info.State == StateFailed {
+			failed = append(failed, ev)
+		}
+	}
+	pm.Output = func(ProcessInfo, string) io.Writer { return io.Discard }
+	// Fails (printing a compiler error) until the "fixed" file exists.
+	if err := pm.AddProcessSpec(Execute{
+		Name:        "build",
+		Cmd:         "test -f fixed || { echo './main.go:3:1: syntax error' 1>&2; exit 1; }",
+		Type:        Blocking,
+		Diagnostics: []string{"go"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := pm.Start(ctx); err == nil {
+		t.Fatal("expected Start to fail")
+	}
+	want := Diagnostic{Process: "build", Parser: "go", File: "./main.go", Line: 3, Column: 1, Message: "syntax error"}
+	if len(failed) != 1 || len(failed[0].Diagnostics) != 1 || failed[0].Diagnostics[0] != want {
+		t.Fatalf("failed events = %+v, want one carrying %+v", failed, want)
+	}
+	if got := pm.Diagnostics(); len(got) != 1 || got[0] != want {
+		t.Errorf("Diagnostics() = %+v, want [%+v]", got, want)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "fixed"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := pm.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := pm.Diagnostics(); got != nil {
+		t.Errorf("Diagnostics() after a good cycle = %+v, want nil", got)
+	}
+}
+
+// TestArgsAndShell checks the argv form reaches the program unsplit and that a
+// configured shell runs command strings, with -c added to a bare program.
+func TestArgsAndShell(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []Execute{
+		{Args: []string{"touch", "with space"}, Type: Blocking},
+		{Cmd: "false; touch not-reached", Shell: "sh -ec", Type: Blocking},
+		{Cmd: "touch bare", Shell: "sh", Type: Blocking},
+	} {
+		if err := pm.AddProcessSpec(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range pm.Processes {
+		_ = pm.runBlocking(context.Background(), p)
+	}
+	if _, err := os.Stat(filepath.Join(root, "with space")); err != nil {
+		t.Errorf("args: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "not-reached")); err == nil {
+		t.Error("shell flags were not passed: sh -e kept going after false")
+	}
+	if _, err := os.Stat(filepath.Join(root, "bare")); err != nil {
+		t.Errorf("bare shell: %v", err)
+	}
+	if got := pm.Processes[0].Exec; got != `touch "with space"` {
+		t.Errorf("args display = %s", got)
+	}
+	if err := pm.AddProcessSpec(Execute{Cmd: "app", Args: []string{"app"}, Type: Primary}); err == nil {
+		t.Error("accepted both cmd and args")
+	}
+}
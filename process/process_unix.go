@@ -3,20 +3,23 @@
 package process
 
 import (
+	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 )
 
-// shellInvocation returns the shell and arguments used to run a command string,
-// so commands may use shell features (quoting, pipes, &&, redirection).
-func shellInvocation(command string) (string, []string) {
-	return "/bin/sh", []string{"-c", command}
-}
+// defaultShell runs command strings when no shell is configured.
+const defaultShell = "/bin/sh -c"
 
 // setProcessGroup puts the command in its own process group so the entire tree
 // (the child and anything it spawns) can be signalled together.
 func setProcessGroup(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
 }
 
 // killProcessTree force-kills the command's whole process group, falling back to
@@ -30,3 +33,53 @@ func killProcessTree(cmd *exec.Cmd) error {
 	}
 	return cmd.Process.Kill()
 }
+
+// inheritListeners passes sockets to the command as file descriptors 3, 4, ...
+// with LISTEN_FDS set. LISTEN_PID must be the pid of the program itself, which
+// is only known once it runs, so a shell exports its own pid and then execs the
+// program in its place: the spec's Args, or a simple command string. The latter
+// goes through env, which applies any leading NAME=value words (exec would take
+// the first one for the program) and execs the program under the same pid. A
+// compound command string (a pipeline or list), or one for a configured shell,
+// runs under that shell, whose pid it then carries.
+func inheritListeners(cmd *exec.Cmd, spec Execute, files []*os.File) error {
+	script := `LISTEN_PID=$$; export LISTEN_PID; exec `
+	argv := spec.Args
+	if len(argv) == 0 {
+		if spec.Shell == "" && !strings.ContainsAny(spec.Cmd, ";&|\n(){}`") {
+			script += "env " + spec.Cmd
+		} else {
+			shell, args := shellInvocation(spec.Shell, spec.Cmd)
+			argv = append([]string{shell}, args...)
+		}
+	}
+	if len(argv) > 0 {
+		script += `"$0" "$@"`
+	}
+	sh := exec.Command("/bin/sh", append([]string{"-c", script}, argv...)...)
+	cmd.Path, cmd.Args, cmd.Err = sh.Path, sh.Args, sh.Err
+	cmd.ExtraFiles = files
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "LISTEN_FDS="+strconv.Itoa(len(files)))
+	return nil
+}
+
+// setNice sets the scheduling priority of the process, or on Linux of the
+// thread with that id.
+func setNice(pid, nice int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+}
+
+// cpuLimitHit reports whether the process died from SIGXCPU or, when wrapped
+// in the shell that set its rlimits, the shell reported the command doing so.
+func cpuLimitHit(state *os.ProcessState, wrapped bool) bool {
+	if state == nil {
+		return false
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGXCPU {
+		return true
+	}
+	return wrapped && state.ExitCode() == 128+int(syscall.SIGXCPU)
+}
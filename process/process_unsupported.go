@@ -2,12 +2,14 @@
 
 package process
 
-import "os/exec"
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
 
-// shellInvocation falls back to /bin/sh on platforms without a known shell.
-func shellInvocation(command string) (string, []string) {
-	return "/bin/sh", []string{"-c", command}
-}
+// defaultShell falls back to /bin/sh on platforms without a known shell.
+const defaultShell = "/bin/sh -c"
 
 // setProcessGroup is a no-op on platforms without process-group support.
 func setProcessGroup(cmd *exec.Cmd) {}
@@ -19,3 +21,24 @@ func killProcessTree(cmd *exec.Cmd) error {
 	}
 	return cmd.Process.Kill()
 }
+
+// inheritListeners is unsupported: child processes cannot inherit extra file
+// descriptors here.
+func inheritListeners(cmd *exec.Cmd, spec Execute, files []*os.File) error {
+	return errors.New("listen (socket handoff) is not supported on this platform")
+}
+
+// wrapRlimits is unsupported: there are no rlimits on this platform.
+func wrapRlimits(cmd *exec.Cmd, limits Limits) error {
+	return errors.New("memory, CPU and open file limits are not supported on this platform")
+}
+
+// setNice is unsupported on this platform.
+func setNice(pid, nice int) error {
+	return errors.New("nice is not supported on this platform")
+}
+
+// cpuLimitHit is always false without a CPU limit.
+func cpuLimitHit(state *os.ProcessState, wrapped bool) bool {
+	return false
+}
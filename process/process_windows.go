@@ -3,15 +3,15 @@
 package process
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"strconv"
 )
 
-// shellInvocation returns the shell and arguments used to run a command string,
-// so commands may use shell features. Windows uses cmd.exe.
-func shellInvocation(command string) (string, []string) {
-	return "cmd", []string{"/C", command}
-}
+// defaultShell runs command strings when no shell is configured. Windows uses
+// cmd.exe.
+const defaultShell = "cmd /C"
 
 // setProcessGroup is a no-op on Windows; process-tree termination is handled by
 // taskkill /T in killProcessTree.
@@ -24,3 +24,24 @@ func killProcessTree(cmd *exec.Cmd) error {
 	}
 	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
 }
+
+// inheritListeners is unsupported: child processes cannot inherit extra file
+// descriptors here.
+func inheritListeners(cmd *exec.Cmd, spec Execute, files []*os.File) error {
+	return errors.New("listen (socket handoff) is not supported on this platform")
+}
+
+// wrapRlimits is unsupported: there are no rlimits on Windows.
+func wrapRlimits(cmd *exec.Cmd, limits Limits) error {
+	return errors.New("memory, CPU and open file limits are not supported on Windows")
+}
+
+// setNice is unsupported on Windows.
+func setNice(pid, nice int) error {
+	return errors.New("nice is not supported on Windows")
+}
+
+// cpuLimitHit is always false without a CPU limit.
+func cpuLimitHit(state *os.ProcessState, wrapped bool) bool {
+	return false
+}
@@ -0,0 +1,187 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RestartStrategy selects how a primary's running instance is replaced on
+// reload.
+type RestartStrategy string
+
+const (
+	// StopFirst stops the old instance before starting the new one. It is the
+	// default.
+	StopFirst RestartStrategy = "stop-first"
+	// StartFirst starts the new instance and stops the old one only once the new
+	// one is ready; if it is not, the old one keeps running.
+	StartFirst RestartStrategy = "start-first"
+)
+
+func (s RestartStrategy) valid() bool {
+	return s == "" || s == StopFirst || s == StartFirst
+}
+
+const (
+	// defaultReadyTimeout bounds a readiness check when Execute.ReadyTimeout is
+	// unset.
+	defaultReadyTimeout = 10 * time.Second
+	// readyGrace is how long a new instance without a readiness check must stay
+	// up to count as ready.
+	readyGrace = time.Second
+)
+
+// restartPrimary replaces the primary's running instance, if any, with a new
+// one according to its restart strategy. Stopping first, the new instance
+// starts once the old one's ports are released.
+func (pm *ProcessManager) restartPrimary(ctx context.Context, p *Process) error {
+	if p.spec.RestartStrategy != StartFirst || !running(p) {
+		pm.stopProcess(p) // no-op on the first run
+		if err := pm.waitPorts(ctx, p); err != nil {
+			pm.transition(p, StateFailed, 0, noExitYet, err)
+			return err
+		}
+		return pm.startAsync(ctx, p)
+	}
+	return pm.startFirst(ctx, p)
+}
+
+// running reports whether the process has a live instance.
+func running(p *Process) bool {
+	if p.done == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// startFirst starts a new instance next to the running one and waits for it to
+// become ready before stopping the old instance. When the new instance fails to
+// start, exits, or is not ready in time, it is stopped, the old instance is
+// restored as the process's current one, and the error is returned.
+func (pm *ProcessManager) startFirst(ctx context.Context, p *Process) error {
+	oldCmd, oldCancel, oldDone := p.cmd, p.cancel, p.done
+	pm.mu.RLock()
+	oldRun, oldPid, oldStarted := p.run, p.pid, p.startedAt
+	pm.mu.RUnlock()
+
+	p.cmd, p.cancel, p.done = nil, nil, nil
+	err := pm.startAsync(ctx, p)
+	if err == nil {
+		if err = pm.awaitReady(ctx, p); err != nil {
+			pm.stopProcess(p)
+		}
+	}
+	if err != nil {
+		select {
+		case <-oldDone:
+			// The old instance exited meanwhile; there is nothing to fall back to.
+			return err
+		default:
+		}
+		slog.Warn("new primary instance failed, keeping the old one", "exec", p.Exec, "pid", oldPid, "err", err)
+		p.cmd, p.cancel, p.done = oldCmd, oldCancel, oldDone
+		pm.resume(p, oldRun, oldPid, oldStarted)
+		return err
+	}
+
+	slog.Debug("new primary instance ready, stopping the old one", "exec", p.Exec, "pid", oldPid)
+	oldCancel()
+	<-oldDone
+	return nil
+}
+
+// resume makes a kept instance the process's current one again after its
+// replacement failed, reporting it as running since its original start.
+func (pm *ProcessManager) resume(p *Process, run, pid int, startedAt time.Time) {
+	pm.mu.Lock()
+	p.run = run
+	p.state = StateRunning
+	p.pid = pid
+	p.startedAt = startedAt
+	ev := ProcessEvent{Info: p.info(), Time: time.Now()}
+	hook := pm.OnEvent
+	pm.mu.Unlock()
+
+	if hook != nil {
+		hook(ev)
+	}
+}
+
+// awaitReady waits until a just-started instance passes its readiness check
+// (Execute.Ready), or, without one, has stayed up for readyGrace. It fails when
+// the instance exits first or the check does not pass within the timeout.
+func (pm *ProcessManager) awaitReady(ctx context.Context, p *Process) error {
+	done := p.done
+	if p.spec.Ready == "" {
+		select {
+		case <-done:
+			return errors.New("exited before it was ready")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(readyGrace):
+			return nil
+		}
+	}
+
+	timeout := defaultReadyTimeout
+	if p.spec.ReadyTimeout > 0 {
+		timeout = time.Duration(p.spec.ReadyTimeout) * time.Millisecond
+	}
+	deadline := time.After(timeout)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		err := probeReady(ctx, p.spec.Ready)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-done:
+			return errors.New("exited before it was ready")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("not ready after %s: %w", timeout, err)
+		case <-tick.C:
+		}
+	}
+}
+
+// probeReady runs one readiness check: a TCP dial for "host:port", or a GET
+// that must answer below 400 for an http(s) URL.
+func probeReady(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", target)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s answered %s", target, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestStartFirstRestart checks a start-first primary is replaced only once the
+// new instance is up, and that a new instance that fails leaves the old one
+// running and current.
+func TestStartFirstRestart(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	err := pm.AddProcessSpec(Execute{
+		Name:            "app",
+		Cmd:             "if [ -f broken ]; then exit 3; fi; sleep 30",
+		Type:            Primary,
+		RestartStrategy: StartFirst,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	pid1 := pm.Snapshot()[0].PID
+
+	if err := pm.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	info := pm.Snapshot()[0]
+	if info.State != StateRunning || info.PID == pid1 || !alive(info.PID) {
+		t.Fatalf("after reload: %+v, want a new running instance", info)
+	}
+	if !waitFor(func() bool { return !alive(pid1) }) {
+		t.Errorf("old instance %d still running", pid1)
+	}
+	pid2 := info.PID
+
+	if err := os.WriteFile(filepath.Join(root, "broken"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = pm.Reload(ctx)
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.ExitCode != 3 {
+		t.Fatalf("Reload with a failing instance = %v, want a step error with exit code 3", err)
+	}
+	info = pm.Snapshot()[0]
+	if info.State != StateRunning || info.PID != pid2 || !alive(pid2) {
+		t.Fatalf("after failed reload: %+v, want instance %d still running", info, pid2)
+	}
+}
+
+// TestReadinessCheck checks a start-first instance that never passes its check
+// is stopped once the timeout passes.
+func TestReadinessCheck(t *testing.T) {
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	err := pm.AddProcessSpec(Execute{
+		Cmd:             "sleep 30",
+		Type:            Primary,
+		RestartStrategy: StartFirst,
+		Ready:           "127.0.0.1:1",
+		ReadyTimeout:    300,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	pid1 := pm.Snapshot()[0].PID
+	if err := pm.Reload(ctx); err == nil {
+		t.Fatal("Reload succeeded with an instance that never became ready")
+	}
+	if info := pm.Snapshot()[0]; info.State != StateRunning || info.PID != pid1 {
+		t.Errorf("after failed readiness: %+v, want instance %d", info, pid1)
+	}
+}
+
+func TestInvalidRestartStrategy(t *testing.T) {
+	err := NewProcessManager().AddProcessSpec(Execute{Cmd: "app", Type: Primary, RestartStrategy: "rolling"})
+	if err == nil {
+		t.Fatal("accepted an unknown restart strategy")
+	}
+}
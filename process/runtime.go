@@ -0,0 +1,203 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Add registers a new process while the manager is running. Once the initial
+// pass has completed, background and primary processes start immediately and a
+// once step runs now (its only chance); blocking steps join the next cycle.
+// Like Start and Reload, it must be called from the supervising goroutine.
+func (pm *ProcessManager) Add(ctx context.Context, spec Execute) error {
+	p, err := newProcess(spec)
+	if err != nil {
+		return err
+	}
+	pm.mu.Lock()
+	if pm.lookup(p.displayName()) != nil {
+		pm.mu.Unlock()
+		return fmt.Errorf("a process named %q already exists", p.displayName())
+	}
+	pm.Processes = append(pm.Processes, p)
+	pm.mu.Unlock()
+	pm.transition(p, StatePending, 0, keepExitCode, nil)
+	if !pm.started {
+		return nil
+	}
+	return pm.launch(ctx, p)
+}
+
+// Remove stops the named process (waiting for it to exit) and drops it from
+// the manager, reporting StateRemoved. Must be called from the supervising
+// goroutine.
+func (pm *ProcessManager) Remove(name string) error {
+	p, err := pm.named(name)
+	if err != nil {
+		return err
+	}
+	pm.stopProcess(p)
+	pm.mu.Lock()
+	pm.Processes = slices.DeleteFunc(pm.Processes, func(q *Process) bool { return q == p })
+	pm.mu.Unlock()
+	pm.releaseListeners()
+	pm.transition(p, StateRemoved, 0, keepExitCode, nil)
+	return nil
+}
+
+// Update replaces the named process's spec in place, keeping its position in
+// the cycle and its buffered output. A process that was running is stopped and
+// restarted with the new spec. The name is kept when spec.Name is empty. Must be
+// called from the supervising goroutine.
+func (pm *ProcessManager) Update(ctx context.Context, name string, spec Execute) error {
+	if spec.Name == "" {
+		spec.Name = name
+	}
+	next, err := newProcess(spec)
+	if err != nil {
+		return err
+	}
+	pm.mu.RLock()
+	p := pm.lookup(name)
+	clash := spec.Name != name && pm.lookup(spec.Name) != nil
+	pm.mu.RUnlock()
+	if p == nil {
+		return fmt.Errorf("no process named %q", name)
+	}
+	if clash {
+		return fmt.Errorf("a process named %q already exists", spec.Name)
+	}
+
+	defer pm.change()()
+	wasRunning := running(p)
+	pm.stopProcess(p)
+	pm.mu.Lock()
+	next.logs = p.logs
+	if next.logs != nil {
+		next.logs.rename(next.displayName())
+	}
+	pm.Processes[slices.Index(pm.Processes, p)] = next
+	pm.mu.Unlock()
+	pm.releaseListeners()
+
+	if pm.started && (wasRunning || next.Type != p.Type) {
+		return pm.launch(ctx, next)
+	}
+	pm.transition(next, StatePending, 0, keepExitCode, nil)
+	return nil
+}
+
+// Reorder arranges processes in the order names are given, which is the order
+// reload cycles run them in. Processes not named keep their relative order
+// after the named ones; unknown names are skipped.
+func (pm *ProcessManager) Reorder(names []string) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	ordered := make([]*Process, 0, len(pm.Processes))
+	for _, name := range names {
+		if p := pm.lookup(name); p != nil && !slices.Contains(ordered, p) {
+			ordered = append(ordered, p)
+		}
+	}
+	for _, p := range pm.Processes {
+		if !slices.Contains(ordered, p) {
+			ordered = append(ordered, p)
+		}
+	}
+	pm.Processes = ordered
+}
+
+// Spec returns the Execute the named process was configured with.
+func (pm *ProcessManager) Spec(name string) (Execute, bool) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	if p := pm.lookup(name); p != nil {
+		return p.spec, true
+	}
+	return Execute{}, false
+}
+
+// StartProcess starts the named process on its own, outside a reload cycle:
+// background and primary processes, and the schedule of a periodic task, are
+// started if not already running, and once or blocking steps run to completion
+// now, returning their error. Must be called from the supervising goroutine.
+func (pm *ProcessManager) StartProcess(ctx context.Context, name string) error {
+	p, err := pm.named(name)
+	if err != nil {
+		return err
+	}
+	if running(p) {
+		return fmt.Errorf("process %q is already running", name)
+	}
+	// Clear the handles of an instance that exited on its own.
+	pm.stopProcess(p)
+	return pm.runOne(ctx, p)
+}
+
+// StopProcess stops the named process if it is running and waits for it to
+// exit; it reports StateKilled. A stopped background process stays down until
+// started again, while a stopped primary is restarted by the next reload cycle.
+// Must be called from the supervising goroutine.
+func (pm *ProcessManager) StopProcess(name string) error {
+	p, err := pm.named(name)
+	if err != nil {
+		return err
+	}
+	pm.stopProcess(p)
+	return nil
+}
+
+// RestartProcess stops the named process (if running) and starts it again; for
+// once and blocking steps this re-runs the step to completion, and a primary is
+// replaced per its restart strategy. Must be called from the supervising
+// goroutine.
+func (pm *ProcessManager) RestartProcess(ctx context.Context, name string) error {
+	p, err := pm.named(name)
+	if err != nil {
+		return err
+	}
+	if p.Type == Primary {
+		return pm.restartPrimary(ctx, p)
+	}
+	pm.stopProcess(p)
+	return pm.runOne(ctx, p)
+}
+
+// named looks a process up by name, erroring when there is none.
+func (pm *ProcessManager) named(name string) (*Process, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+	if p := pm.lookup(name); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("no process named %q", name)
+}
+
+// launch runs a process that joined after the initial pass: like runOne, except
+// blocking steps are left for the next cycle.
+func (pm *ProcessManager) launch(ctx context.Context, p *Process) error {
+	if p.Type == Blocking {
+		return nil
+	}
+	return pm.runOne(ctx, p)
+}
+
+// runOne runs a single process outside a cycle according to its type:
+// long-lived processes and schedules are started and once/blocking steps run
+// to completion.
+func (pm *ProcessManager) runOne(ctx context.Context, p *Process) error {
+	if p.Exec == KILL_EXEC || p.Exec == REFRESH_EXEC {
+		return nil
+	}
+	switch p.Type {
+	case Primary:
+		return pm.restartPrimary(ctx, p)
+	case Background:
+		return pm.startAsync(ctx, p)
+	case Periodic:
+		return pm.startSchedule(ctx, p)
+	default:
+		return pm.runBlocking(ctx, p)
+	}
+}
@@ -0,0 +1,230 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a periodic task runs.
+type Schedule interface {
+	// Next returns the first run time strictly after after, or the zero time
+	// when there is none.
+	Next(after time.Time) time.Time
+}
+
+// ParseSchedule parses a periodic task's schedule: a Go duration such as "10m"
+// or "1h30m" to run at that interval, starting at once, or a five-field cron
+// expression ("minute hour day-of-month month day-of-week", in local time) or
+// one of @hourly, @daily (@midnight), @weekly, @monthly and @yearly
+// (@annually) to run at the matching times.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, errors.New("schedule is required")
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("schedule %q: interval must be positive", spec)
+		}
+		return interval(d), nil
+	}
+	if expr, ok := cronShorthands[spec]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q is neither a duration nor a cron expression of 5 fields", spec)
+	}
+	var c cron
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+	}{
+		{&c.minute, 0, 59},
+		{&c.hour, 0, 23},
+		{&c.dom, 1, 31},
+		{&c.month, 1, 12},
+		{&c.dow, 0, 7},
+	} {
+		set, err := parseCronField(fields[i], f.min, f.max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %w", spec, err)
+		}
+		*f.set = set
+	}
+	// Sunday is 0 or 7.
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.anyDom = fields[2] == "*"
+	c.anyDow = fields[4] == "*"
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never matches", spec)
+	}
+	return c, nil
+}
+
+// interval runs a task every so often.
+type interval time.Duration
+
+func (i interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// cron holds the matching values of each field as bit sets. As in cron, when
+// both day fields are restricted a day matching either one matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+// parseCronField parses a comma-separated list of *, n, a-b, each optionally
+// followed by /step.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepStr)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+func (c cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Every combination recurs within a few years (29 February every 4, or 8
+	// across a skipped leap year).
+	limit := t.AddDate(9, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<t.Weekday()) != 0
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// startSchedule starts the scheduler of a periodic task. Its runs go through
+// runBlocking, so they report the usual events and output; stopProcess stops
+// the scheduler together with a run in progress.
+func (pm *ProcessManager) startSchedule(ctx context.Context, p *Process) error {
+	procCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	p.cancel, p.done = cancel, done
+	go func() {
+		defer close(done)
+		pm.runSchedule(procCtx, p)
+	}()
+	return nil
+}
+
+// runSchedule runs a periodic task on its schedule until ctx is cancelled. An
+// interval task first runs right away. Runs never overlap: the times a run
+// outlasts are skipped, as are those that come while the manager is paused. A
+// run that falls due during a reload or update waits for it to finish. Stopping
+// the task reports StateKilled, whether or not a run was in progress.
+func (pm *ProcessManager) runSchedule(ctx context.Context, p *Process) {
+	due := time.Now()
+	if _, ok := p.schedule.(interval); !ok {
+		due = p.schedule.Next(due)
+	}
+	for !due.IsZero() {
+		timer := time.NewTimer(time.Until(due))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			pm.transition(p, StateKilled, 0, noExitYet, nil)
+			return
+		case <-timer.C:
+		}
+		if pm.paused.Load() {
+			slog.Debug("paused, skipping periodic run", "exec", p.Exec)
+		} else {
+			if !pm.waitSettled(ctx) {
+				pm.transition(p, StateKilled, 0, noExitYet, nil)
+				return
+			}
+			// A cancelled run reports StateKilled itself.
+			if err := pm.runBlocking(ctx, p); err != nil && ctx.Err() == nil {
+				slog.Warn("periodic task failed", "exec", p.Exec, "err", err)
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		now, skipped := time.Now(), 0
+		for due = p.schedule.Next(due); !due.IsZero() && !due.After(now); due = p.schedule.Next(due) {
+			skipped++
+		}
+		if skipped > 0 {
+			slog.Warn("periodic task outlasted its schedule, skipping overlapping runs", "exec", p.Exec, "skipped", skipped)
+		}
+	}
+}
+
+// SetPaused pauses or resumes periodic tasks: while paused their scheduled runs
+// are skipped, and a run in progress completes. Safe to call from any
+// goroutine.
+func (pm *ProcessManager) SetPaused(paused bool) {
+	pm.paused.Store(paused)
+}
@@ -0,0 +1,196 @@
+//go:build linux || darwin
+
+package process
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2025, time.January, 15, 10, 7, 30, 0, time.Local)
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"10m", from.Add(10 * time.Minute)},
+		{"*/15 * * * *", time.Date(2025, time.January, 15, 10, 15, 0, 0, time.Local)},
+		{"@hourly", time.Date(2025, time.January, 15, 11, 0, 0, 0, time.Local)},
+		{"30 9 * * 1-5", time.Date(2025, time.January, 16, 9, 30, 0, 0, time.Local)},
+		{"0 0 * * 7", time.Date(2025, time.January, 19, 0, 0, 0, 0, time.Local)},
+		{"0 0 1,15 * 0", time.Date(2025, time.January, 19, 0, 0, 0, 0, time.Local)},
+		{"0 12 29 2 *", time.Date(2028, time.February, 29, 12, 0, 0, 0, time.Local)},
+	} {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %s, want %s", tc.spec, got, tc.want)
+		}
+	}
+	for _, spec := range []string{"", "-1m", "* * *", "60 * * * *", "0 0 30 2 *", "*/0 * * * *"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q: accepted an invalid schedule", spec)
+		}
+	}
+}
+
+// TestPeriodicTask checks a periodic task runs on its interval from startup,
+// never overlaps a run still in progress, and skips its runs while paused.
+func TestPeriodicTask(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	err := pm.AddProcessSpec(Execute{
+		Name:     "token",
+		Cmd:      "echo start >> runs; sleep 0.3; echo end >> runs",
+		Type:     Periodic,
+		Schedule: "100ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	runs := func() string {
+		data, _ := os.ReadFile(filepath.Join(root, "runs"))
+		return string(data)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	got := runs()
+	if n := strings.Count(got, "start"); n < 2 || n > 4 {
+		t.Errorf("%d runs in 1.1s of 300ms runs every 100ms, want 2 to 4", n)
+	}
+	if strings.Contains(got, "start\nstart") {
+		t.Errorf("runs overlapped:\n%s", got)
+	}
+	if err := pm.Reload(ctx); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	pm.SetPaused(true)
+	time.Sleep(400 * time.Millisecond) // let a run in progress finish
+	paused := runs()
+	time.Sleep(400 * time.Millisecond)
+	if runs() != paused {
+		t.Error("the task ran while paused")
+	}
+	pm.SetPaused(false)
+	if !waitFor(func() bool { return len(runs()) > len(paused) }) {
+		t.Error("the task did not run again after resuming")
+	}
+}
+
+// TestPeriodicTaskWaitsForReload checks a periodic task does not run while a
+// reload cycle is in flight, and runs once it completes.
+func TestPeriodicTaskWaitsForReload(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []Execute{
+		{Name: "token", Cmd: "echo run >> runs", Type: Periodic, Schedule: "50ms"},
+		{Name: "build", Cmd: "sleep 0.5", Type: Blocking},
+	} {
+		if err := pm.AddProcessSpec(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	runs := func() string {
+		data, _ := os.ReadFile(filepath.Join(root, "runs"))
+		return string(data)
+	}
+
+	reloaded := make(chan error, 1)
+	go func() { reloaded <- pm.Reload(ctx) }()
+	time.Sleep(100 * time.Millisecond) // let a run in progress finish
+	during := runs()
+	time.Sleep(300 * time.Millisecond)
+	if runs() != during {
+		t.Error("the task ran during a reload")
+	}
+	if err := <-reloaded; err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !waitFor(func() bool { return len(runs()) > len(during) }) {
+		t.Error("the task did not run again after the reload")
+	}
+}
+
+// TestPeriodicTaskDefersDuringUpdate checks a run that falls due while another
+// process is being updated is held until the update finishes rather than
+// dropped, and that stopping the idle task reports it killed.
+func TestPeriodicTaskDefersDuringUpdate(t *testing.T) {
+	root := t.TempDir()
+	pm := NewProcessManager()
+	if err := pm.SetRootDirectory(root); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []Execute{
+		{Name: "token", Cmd: "echo run >> runs", Type: Periodic, Schedule: "300ms"},
+		{Name: "worker", Cmd: "sleep 30", Type: Background},
+	} {
+		if err := pm.AddProcessSpec(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer pm.Shutdown()
+	if err := pm.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	count := func() int {
+		data, _ := os.ReadFile(filepath.Join(root, "runs"))
+		return strings.Count(string(data), "run")
+	}
+	if !waitFor(func() bool { return count() == 1 }) {
+		t.Fatal("the task did not run at startup")
+	}
+
+	// Turning the worker into a once step runs it as part of the update, which
+	// so spans the task's next tick at 300ms.
+	if err := pm.Update(ctx, "worker", Execute{Name: "worker", Cmd: "sleep 0.6", Type: Once}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if n := count(); n != 1 {
+		t.Errorf("%d runs by the end of the update, want 1: the task ran during it", n)
+	}
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := count(); n != 2 {
+		t.Errorf("%d runs right after the update, want the held run made up", n)
+	}
+
+	if err := pm.StopProcess("token"); err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range pm.Snapshot() {
+		if info.Name == "token" && info.State != StateKilled {
+			t.Errorf("stopped task is %s, want %s", info.State, StateKilled)
+		}
+	}
+}